@@ -0,0 +1,49 @@
+package sjson
+
+// Stats holds structural statistics about a JSON document.
+type Stats struct {
+	Objects  int
+	Arrays   int
+	Strings  int
+	Numbers  int
+	Booleans int
+	Nulls    int
+	// Members is the total number of object members.
+	Members int
+	// MaxDepth is the deepest nesting of arrays and objects, where 1 is a
+	// top-level container.
+	MaxDepth int
+}
+
+func (s *Stats) add(t Token) {
+	switch t.Kind {
+	case TokenBeginObject:
+		s.Objects++
+	case TokenBeginArray:
+		s.Arrays++
+	case TokenKey:
+		s.Members++
+	case TokenValue:
+		switch t.Type {
+		case String:
+			s.Strings++
+		case Number:
+			s.Numbers++
+		case Bool:
+			s.Booleans++
+		case Null:
+			s.Nulls++
+		}
+	}
+}
+
+// Analyze computes structural statistics for the single JSON value contained
+// in data, in one pass and without buffering it. If data is malformed, the
+// statistics gathered before the error are returned along with it.
+func Analyze(data []byte) (Stats, error) {
+	var stats Stats
+	p := &Parser{discard: true, tokenFn: stats.add}
+	_, err := parseSingle(p, data)
+	stats.MaxDepth = p.MaxDepthReached()
+	return stats, err
+}
@@ -0,0 +1,38 @@
+package sjson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyze(t *testing.T) {
+	stats, err := Analyze([]byte(`{"a": [1, 2.5, "x", true, false, null], "b": {"c": {}}, "d": [[]]} `))
+	require.NoError(t, err)
+	assert.Equal(t, Stats{
+		Objects:  3,
+		Arrays:   3,
+		Strings:  1,
+		Numbers:  2,
+		Booleans: 2,
+		Nulls:    1,
+		Members:  4,
+		MaxDepth: 3,
+	}, stats)
+
+	stats, err = Analyze([]byte(`-12`))
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Numbers: 1}, stats)
+}
+
+func TestAnalyzePartial(t *testing.T) {
+	stats, err := Analyze([]byte(`[1, "a", {"b": nul}]`))
+	require.Error(t, err)
+	assert.Equal(t, Stats{Objects: 1, Arrays: 1, Strings: 1, Numbers: 1, Members: 1, MaxDepth: 2}, stats)
+
+	_, err = Analyze([]byte(`[] []`))
+	assert.Error(t, err)
+	_, err = Analyze(nil)
+	assert.Error(t, err)
+}
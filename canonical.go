@@ -0,0 +1,261 @@
+package sjson
+
+import (
+	"bytes"
+	"hash"
+	"sort"
+	"unicode/utf8"
+)
+
+// Canonicalize parses the single JSON value contained in data and returns it
+// in a canonical form: without whitespace, with the members of every object
+// sorted by key, and with strings re-escaped so only quotes, backslashes and
+// control characters are escaped. Keys are compared byte-wise after escape
+// sequences are resolved, and members sharing a key keep their relative
+// order. Numbers are returned as found in data. Strings holding malformed
+// UTF-8 are rejected.
+func Canonicalize(data []byte) ([]byte, error) {
+	value, err := parseSingle(&Parser{}, data)
+	if err != nil {
+		return nil, err
+	}
+	c := canonicalizer{valueDecoder{data: value, u: &Unmarshaler{}}}
+	return c.value(make([]byte, 0, len(value)))
+}
+
+// canonicalizer rewrites a value previously validated by a Parser.
+type canonicalizer struct {
+	valueDecoder
+}
+
+// canonicalMember locates the value of an object member within the data
+// being canonicalized.
+type canonicalMember struct {
+	key   string
+	start int
+}
+
+func (c *canonicalizer) value(out []byte) ([]byte, error) {
+	switch c.data[c.pos] {
+	case quote:
+		s, err := c.decodeString()
+		if err != nil {
+			return nil, err
+		}
+		return appendCanonicalString(out, s), nil
+	case leftSquared:
+		return c.array(out)
+	case leftCurly:
+		return c.object(out)
+	default:
+		start := c.pos
+		for c.pos < len(c.data) && !bytes.ContainsRune([]byte(",]}"), rune(c.data[c.pos])) {
+			c.pos++
+		}
+		return append(out, c.data[start:c.pos]...), nil
+	}
+}
+
+func (c *canonicalizer) array(out []byte) ([]byte, error) {
+	out = append(out, leftSquared)
+	c.pos++
+	for c.data[c.pos] != rightSquared {
+		if c.data[c.pos] == ',' {
+			out = append(out, ',')
+			c.pos++
+		}
+		var err error
+		if out, err = c.value(out); err != nil {
+			return nil, err
+		}
+	}
+	c.pos++
+	return append(out, rightSquared), nil
+}
+
+func (c *canonicalizer) object(out []byte) ([]byte, error) {
+	members, err := c.members()
+	if err != nil {
+		return nil, err
+	}
+	end := c.pos
+	out = append(out, leftCurly)
+	for i, m := range members {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		out = appendCanonicalString(out, m.key)
+		out = append(out, ':')
+		c.pos = m.start
+		if out, err = c.value(out); err != nil {
+			return nil, err
+		}
+	}
+	c.pos = end
+	return append(out, rightCurly), nil
+}
+
+// members reads an object, returning the keys and locations of its members
+// sorted by key. Values are skipped over, and left for the caller to
+// canonicalize.
+func (c *canonicalizer) members() ([]canonicalMember, error) {
+	var members []canonicalMember
+	c.pos++
+	for c.data[c.pos] != rightCurly {
+		if c.data[c.pos] == ',' {
+			c.pos++
+		}
+		key, err := c.decodeString()
+		if err != nil {
+			return nil, err
+		}
+		c.pos++ // ':'
+		start := c.pos
+		c.skip()
+		members = append(members, canonicalMember{key, start})
+	}
+	c.pos++
+
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].key < members[j].key
+	})
+	return members, nil
+}
+
+// skip advances past the value at the current position.
+func (c *canonicalizer) skip() {
+	depth := 0
+	for ; ; c.pos++ {
+		switch c.data[c.pos] {
+		case quote:
+			for c.pos++; c.data[c.pos] != quote; c.pos++ {
+				if c.data[c.pos] == '\\' {
+					c.pos++
+				}
+			}
+		case leftSquared, leftCurly:
+			depth++
+		case rightSquared, rightCurly:
+			if depth == 0 {
+				return
+			}
+			if depth--; depth == 0 {
+				c.pos++
+				return
+			}
+		case ',':
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+// CanonicalHash parses the single JSON value contained in data and writes
+// its canonical form, as returned by Canonicalize, to h, returning the
+// resulting hash. Values equal but for the order of their members, their
+// whitespace or the escaping of their strings thus hash identically, as in:
+//
+//	sum, err := CanonicalHash(data, sha256.New())
+//
+// The canonical form is written as it is produced. Besides the parsed value,
+// only the keys and value locations of the objects enclosing the one being
+// written are held in memory, as members must be sorted. h is not reset
+// beforehand.
+func CanonicalHash(data []byte, h hash.Hash) ([]byte, error) {
+	value, err := parseSingle(&Parser{}, data)
+	if err != nil {
+		return nil, err
+	}
+	c := canonicalizer{valueDecoder{data: value, u: &Unmarshaler{}}}
+	if err := c.write(h, nil); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// write writes the canonical form of the value at the current position to
+// h, using scratch as a working buffer.
+func (c *canonicalizer) write(h hash.Hash, scratch []byte) error {
+	switch c.data[c.pos] {
+	case leftSquared:
+		h.Write([]byte{leftSquared})
+		c.pos++
+		for c.data[c.pos] != rightSquared {
+			if c.data[c.pos] == ',' {
+				h.Write([]byte{','})
+				c.pos++
+			}
+			if err := c.write(h, scratch); err != nil {
+				return err
+			}
+		}
+		c.pos++
+		h.Write([]byte{rightSquared})
+	case leftCurly:
+		members, err := c.members()
+		if err != nil {
+			return err
+		}
+		end := c.pos
+		h.Write([]byte{leftCurly})
+		for i, m := range members {
+			if i > 0 {
+				h.Write([]byte{','})
+			}
+			scratch = append(appendCanonicalString(scratch[:0], m.key), ':')
+			h.Write(scratch)
+			c.pos = m.start
+			if err := c.write(h, scratch); err != nil {
+				return err
+			}
+		}
+		c.pos = end
+		h.Write([]byte{rightCurly})
+	default:
+		out, err := c.value(scratch[:0])
+		if err != nil {
+			return err
+		}
+		h.Write(out)
+	}
+	return nil
+}
+
+// appendCanonicalString appends s to out as a quoted JSON string, escaping
+// only the characters that must be escaped.
+func appendCanonicalString(out []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	out = append(out, quote)
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			out = append(out, s[i:i+size]...)
+			i += size
+			continue
+		}
+		switch c {
+		case quote, '\\':
+			out = append(out, '\\', c)
+		case '\b':
+			out = append(out, '\\', 'b')
+		case '\f':
+			out = append(out, '\\', 'f')
+		case '\n':
+			out = append(out, '\\', 'n')
+		case '\r':
+			out = append(out, '\\', 'r')
+		case '\t':
+			out = append(out, '\\', 't')
+		default:
+			if c < 0x20 {
+				out = append(out, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			} else {
+				out = append(out, c)
+			}
+		}
+		i++
+	}
+	return append(out, quote)
+}
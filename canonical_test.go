@@ -0,0 +1,72 @@
+package sjson
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	cases := map[string]string{
+		`1.50`:                        `1.50`,
+		` "a" `:                       `"a"`,
+		`[ ]`:                         `[]`,
+		`{ }`:                         `{}`,
+		`{"b": 1, "a": [true, null]}`: `{"a":[true,null],"b":1}`,
+		`{"z": {"y": 1, "x": 2}, "a": [{"d": 1, "c": 2}]}`: `{"a":[{"c":2,"d":1}],"z":{"x":2,"y":1}}`,
+		`"A\/é\n\u001f\""`:                        "\"A/é\\n\\u001f\\\"\"",
+		`{"b": 1, "a": 2}`:                        `{"a":2,"b":1}`,
+		`{"a": 2, "a": 1}`:                        `{"a":2,"a":1}`,
+		`[-0, 1e10, {"k": "v"}]`:                  `[-0,1e10,{"k":"v"}]`,
+		`{"b": ["]}", "\"{,"], "a": {"c": "\\"}}`: `{"a":{"c":"\\"},"b":["]}","\"{,"]}`,
+	}
+	for in, expected := range cases {
+		out, err := Canonicalize([]byte(in))
+		require.NoError(t, err, in)
+		assert.Equal(t, expected, string(out), in)
+	}
+
+	for _, in := range []string{``, `{"a":}`, `[1] [2]`, "[\"\xff\"]"} {
+		_, err := Canonicalize([]byte(in))
+		assert.Error(t, err, in)
+	}
+}
+
+func TestCanonicalHash(t *testing.T) {
+	docs := []string{
+		`{"b": [1, {"y": "A", "x": null}], "a": "é", "c": {}}`,
+		` { "c" : { } , "a" : "\u00e9", "b" : [ 1 , { "x" : null , "y" : "\u0041" } ] } `,
+		`{"a":"é","c":{},"b":[1,{"y":"A","x":null}]}`,
+	}
+	var sums [][]byte
+	for _, doc := range docs {
+		sum, err := CanonicalHash([]byte(doc), sha256.New())
+		require.NoError(t, err, doc)
+		sums = append(sums, sum)
+	}
+	assert.Equal(t, sums[0], sums[1])
+	assert.Equal(t, sums[0], sums[2])
+
+	canonical, err := Canonicalize([]byte(docs[0]))
+	require.NoError(t, err)
+	want := sha256.Sum256(canonical)
+	assert.Equal(t, want[:], sums[0])
+
+	for _, doc := range []string{`[1, "a", true, null, [{"b": 2, "a": 1}]]`, `"x"`, `-1.5e3`, `{}`, `{"b": ["]}", "\"{,"], "a": {"c": "\\"}}`} {
+		canonical, err := Canonicalize([]byte(doc))
+		require.NoError(t, err)
+		want := sha256.Sum256(canonical)
+		sum, err := CanonicalHash([]byte(doc), sha256.New())
+		require.NoError(t, err)
+		assert.Equal(t, want[:], sum, doc)
+	}
+
+	other, err := CanonicalHash([]byte(`{"a": "é", "b": [1, {"x": null, "y": "B"}], "c": {}}`), sha256.New())
+	require.NoError(t, err)
+	assert.NotEqual(t, sums[0], other)
+
+	_, err = CanonicalHash([]byte(`{"a": }`), sha256.New())
+	assert.Error(t, err)
+}
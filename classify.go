@@ -0,0 +1,46 @@
+package sjson
+
+// Classification is the verdict of the parser over a document, in the terms
+// used by JSONTestSuite.
+type Classification int
+
+const (
+	// Rejected indicates the document is not valid JSON.
+	Rejected Classification = iota
+	// Accepted indicates the document is valid JSON.
+	Accepted
+	// Either is used for the indifferent ("i_") cases of JSONTestSuite,
+	// where the specification allows parsers to either accept or reject
+	// the input. Classify never returns it; it exists so suites can express
+	// expectations using the same type.
+	Either
+)
+
+func (c Classification) String() string {
+	switch c {
+	case Rejected:
+		return "rejected"
+	case Accepted:
+		return "accepted"
+	case Either:
+		return "either"
+	default:
+		return "invalid"
+	}
+}
+
+// Classify returns whether the parser accepts data as a single JSON value.
+// Inputs from the indifferent category are reported as either Accepted or
+// Rejected, according to what the parser actually does with them.
+func Classify(data []byte) Classification {
+	if _, err := Parse(data); err != nil {
+		return Rejected
+	}
+	return Accepted
+}
+
+// Matches reports whether c satisfies the expected classification. Either
+// is satisfied by any verdict.
+func (c Classification) Matches(expected Classification) bool {
+	return expected == Either || c == expected
+}
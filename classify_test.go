@@ -0,0 +1,19 @@
+package sjson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	assert.Equal(t, Accepted, Classify([]byte(`{"a":[1,2]}`)))
+	assert.Equal(t, Accepted, Classify([]byte(" 1 ")))
+	assert.Equal(t, Rejected, Classify([]byte(`{"a":`)))
+	assert.Equal(t, Rejected, Classify([]byte("[] []")))
+	assert.Equal(t, Rejected, Classify(nil))
+
+	assert.True(t, Accepted.Matches(Either))
+	assert.True(t, Rejected.Matches(Either))
+	assert.False(t, Rejected.Matches(Accepted))
+}
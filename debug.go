@@ -0,0 +1,55 @@
+package sjson
+
+import (
+	"fmt"
+	"strings"
+)
+
+// debugDumpBytes is how many of the last buffered bytes DebugDump includes.
+const debugDumpBytes = 64
+
+// DebugDump returns a human-readable snapshot of the parser's internal state:
+// its counters, every frame of its state stack and the last bytes it buffered.
+// It is meant to be attached to bug reports, and is safe to call at any time,
+// including while recovering from a panic raised by the parser.
+func (p *Parser) DebugDump() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "offset: %d, depth: %d, buffered: %d\n", p.offset, p.depth, len(p.data))
+	if p.err != nil {
+		fmt.Fprintf(&sb, "error: %s\n", p.err)
+	}
+	fmt.Fprintf(&sb, "stack (%d):\n", len(p.stack))
+	for i, s := range p.stack {
+		fmt.Fprintf(&sb, "  %d: %s position=%d offset=%d index=%d", i, s.name, s.position, s.offset, s.index)
+		if s.keyEnd > 0 && s.keyStart >= 0 && s.keyStart <= s.keyEnd && s.keyEnd <= len(p.data) {
+			fmt.Fprintf(&sb, " key=%s", p.data[s.keyStart:s.keyEnd])
+		}
+		sb.WriteByte('\n')
+	}
+	tail := p.data
+	if len(tail) > debugDumpBytes {
+		tail = tail[len(tail)-debugDumpBytes:]
+	}
+	fmt.Fprintf(&sb, "data (last %d bytes): %q\n", len(tail), tail)
+	return sb.String()
+}
+
+// ContextSnippet returns up to radius bytes around the current position in the
+// value being parsed, to show where an error occurred. As the parser never
+// reads ahead, only bytes it buffered are available: those preceding the
+// position and, when the last byte fed was rejected, that byte itself. The
+// returned slice is a copy.
+func (p *Parser) ContextSnippet(radius int) []byte {
+	if radius <= 0 {
+		return nil
+	}
+	before := p.data
+	if len(before) > radius {
+		before = before[len(before)-radius:]
+	}
+	snippet := append([]byte(nil), before...)
+	if p.err != nil && p.offset > 0 && !p.curBuffered {
+		snippet = append(snippet, p.cur)
+	}
+	return snippet
+}
@@ -0,0 +1,61 @@
+package sjson
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugDump(t *testing.T) {
+	p := &Parser{}
+	assert.Equal(t, "offset: 0, depth: 0, buffered: 0\nstack (0):\ndata (last 0 bytes): \"\"\n", p.DebugDump())
+
+	_, err := feedAll(p, `{"a": [1, "x`)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"offset: 12, depth: 2, buffered: 10",
+		"stack (4):",
+		`  0: pObject position=0 offset=0 index=1 key="a"`,
+		"  1: pObjectValue position=4 offset=1 index=0",
+		"  2: pArray position=5 offset=6 index=2",
+		"  3: pString position=8 offset=10 index=0",
+		`data (last 10 bytes): "{\"a\":[1,\"x"`,
+		"",
+	}, "\n"), p.DebugDump())
+}
+
+func TestDebugDumpIsSafe(t *testing.T) {
+	p := &Parser{}
+	_, err := feedAll(p, "[1,]")
+	require.Error(t, err)
+	assert.Contains(t, p.DebugDump(), "error: failed parsing stream: trailing comma not allowed")
+
+	p.Reset()
+	_, err = feedAll(p, `["`+strings.Repeat("x", 100))
+	require.NoError(t, err)
+	assert.Contains(t, p.DebugDump(), "data (last 64 bytes)")
+
+	p.stack = append(p.stack, state{name: parserState(42), keyStart: 5, keyEnd: 1000})
+	assert.Contains(t, p.DebugDump(), "parserState(42)")
+}
+
+func TestContextSnippet(t *testing.T) {
+	p := &Parser{}
+	assert.Empty(t, p.ContextSnippet(8))
+
+	_, err := feedAll(p, `{"a":1,"bad":xyz}`)
+	require.Error(t, err)
+	assert.Equal(t, `"bad":x`, string(p.ContextSnippet(7)))
+	assert.Equal(t, `{"a":1,"bad":x`, string(p.ContextSnippet(100)))
+	assert.Empty(t, p.ContextSnippet(0))
+
+	p.Reset()
+	_, err = feedAll(p, `[1, 2, 3`)
+	require.NoError(t, err)
+	snippet := p.ContextSnippet(3)
+	assert.Equal(t, `2,3`, string(snippet))
+	snippet[0] = 'x'
+	assert.Equal(t, `2,3`, string(p.ContextSnippet(3)), "snippet must not alias the buffer")
+}
@@ -0,0 +1,334 @@
+package sjson
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+const decoderBufferSize = 4096
+
+// Decoder reads a stream of JSON values from an io.Reader.
+type Decoder struct {
+	// EmptyAsNull makes a stream holding no values at all (being empty or
+	// made only of whitespace) yield a single null value instead of
+	// io.EOF. It has no effect once a value was read, and a stream ending
+	// in a truncated value is still reported as an error.
+	EmptyAsNull bool
+
+	// MeasureDuration makes the decoder record how long each value took to
+	// read, from its first byte to its completion, including time spent
+	// waiting on the reader. It is opt-in, as it costs two calls to
+	// time.Now per value.
+	MeasureDuration bool
+
+	r       io.Reader
+	chained []io.Reader
+	p       Parser
+	buf     []byte
+	pos     int
+	end     int
+	err     error
+	n       int
+
+	closeReader bool
+	timeout     time.Duration
+	// poll is how long a TailDecoder waits before reading again once the
+	// reader is exhausted.
+	poll time.Duration
+
+	start time.Time
+	dur   time.Duration
+
+	rate   int
+	tokens float64
+	refill time.Time
+
+	// now and sleep replace time.Now and sleepContext when set, so tests
+	// can control time.
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewDecoder returns a Decoder reading values from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r, buf: make([]byte, decoderBufferSize)}
+}
+
+// NewDecoderFunc returns a Decoder reading values from the reader returned by
+// wrap(r), so the stream can be decompressed or decoded before it is parsed,
+// as in:
+//
+//	NewDecoderFunc(r, func(r io.Reader) io.Reader {
+//		return base64.NewDecoder(base64.StdEncoding, r)
+//	})
+//
+// If the wrapping reader implements io.Closer, it is closed once it returns an
+// error or io.EOF. r itself is never closed.
+func NewDecoderFunc(r io.Reader, wrap func(io.Reader) io.Reader) *Decoder {
+	d := NewDecoder(wrap(r))
+	d.closeReader = true
+	return d
+}
+
+// Chain makes the decoder read from readers, in order, once the current
+// reader is exhausted. Boundaries between readers are invisible to the
+// parser, so a value may begin in one reader and end in another. Readers may
+// be chained at any time, including after Next returned io.EOF, in which
+// case reading resumes with them.
+func (d *Decoder) Chain(readers ...io.Reader) {
+	d.chained = append(d.chained, readers...)
+}
+
+// Parser returns the Parser used by the decoder, so it can be configured
+// before values are read.
+func (d *Decoder) Parser() *Parser {
+	return &d.p
+}
+
+// Next returns the next value in the stream, or io.EOF once the stream ends
+// after a complete value. The returned slice is only valid until the next
+// call to Next.
+func (d *Decoder) Next() ([]byte, error) {
+	return d.NextContext(context.Background())
+}
+
+// NextContext is like Next, but gives up once ctx is done, returning its
+// error. ctx is checked before each read from the underlying reader and
+// while waiting on the rate limit set by SetRateLimit, but a read blocking
+// indefinitely must be handled by the reader itself. Unlike other errors,
+// ctx's error isn't kept: a later call may resume reading the stream.
+func (d *Decoder) NextContext(ctx context.Context) ([]byte, error) {
+	v, err := d.next(ctx)
+	if err == io.EOF && d.EmptyAsNull && d.n == 0 {
+		v, err = []byte("null"), nil
+	}
+	if err == nil {
+		d.n++
+	}
+	return v, err
+}
+
+// SetValueTimeout makes Next return ErrValueTimeout once a value takes longer
+// than timeout to arrive, measured from its first byte. The partial value is
+// abandoned, and every further call to Next returns the same error. The clock
+// is only checked between reads, so a read blocking indefinitely must be
+// handled by the underlying reader. Zero disables the timeout.
+func (d *Decoder) SetValueTimeout(timeout time.Duration) {
+	d.timeout = timeout
+}
+
+// SetRateLimit limits how many bytes per second the decoder reads from the
+// underlying reader, so a single stream can't monopolize the CPU. Reads are
+// paced by a token bucket holding up to one second worth of bytes, and the
+// decoder blocks while it refills; use NextContext to bound that wait. Zero
+// disables the limit.
+func (d *Decoder) SetRateLimit(bytesPerSec int) {
+	d.rate = bytesPerSec
+	d.tokens = float64(bytesPerSec)
+	d.refill = time.Time{}
+}
+
+// LastDuration returns how long the last value returned by Next took to read.
+// It is zero unless MeasureDuration is set.
+func (d *Decoder) LastDuration() time.Duration {
+	return d.dur
+}
+
+// DecodeNext reads the next value in the stream and returns it decoded as
+// Unmarshal would, or io.EOF once the stream ends after a complete value.
+func (d *Decoder) DecodeNext() (any, error) {
+	v, err := d.Next()
+	if err != nil {
+		return nil, err
+	}
+	dec := valueDecoder{data: v, u: &Unmarshaler{}}
+	return dec.decode()
+}
+
+// ValueReader returns a reader yielding the values read by d, as returned by
+// Next, each followed by sep. This re-serializes the stream once validated
+// and transformed by the parser's options, so it can be forwarded. sep is
+// the only boundary between values in the output: with "\n", the output is
+// NDJSON, while an empty sep concatenates values, which only keeps them
+// apart when none of two adjacent values is a number, true, false or null.
+// Once d returns an error, including io.EOF, the reader returns it after
+// the values read before it. The reader and d must not be used concurrently.
+func (d *Decoder) ValueReader(sep []byte) io.Reader {
+	return &valueReader{d: d, sep: sep}
+}
+
+type valueReader struct {
+	d       *Decoder
+	sep     []byte
+	buf     []byte
+	pending []byte
+	err     error
+}
+
+func (r *valueReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		v, err := r.d.Next()
+		if err != nil {
+			r.err = err
+			continue
+		}
+		r.buf = append(append(r.buf[:0], v...), r.sep...)
+		r.pending = r.buf
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (d *Decoder) next(ctx context.Context) ([]byte, error) {
+	d.dur = 0
+	for {
+		for d.pos < d.end {
+			b := d.buf[d.pos]
+			d.pos++
+			v, err := d.p.Feed(b)
+			if err != nil {
+				return nil, err
+			}
+			if d.MeasureDuration || d.timeout > 0 {
+				d.measure(v != nil)
+			}
+			if v != nil {
+				return v, nil
+			}
+		}
+
+		if d.err != nil {
+			if d.err != io.EOF {
+				return nil, d.err
+			}
+			if len(d.chained) > 0 {
+				d.r, d.chained = d.chained[0], d.chained[1:]
+				d.err, d.closeReader = nil, false
+				continue
+			}
+			if d.poll > 0 {
+				if err := d.wait(ctx, d.poll); err != nil {
+					return nil, err
+				}
+				d.err = nil
+				continue
+			}
+			if len(d.p.stack) == 0 && d.p.held == nil {
+				return nil, io.EOF
+			}
+			v, err := d.p.finish()
+			if err == nil && d.MeasureDuration {
+				d.measure(true)
+			}
+			return v, err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		buf := d.buf
+		if d.rate > 0 {
+			n, err := d.waitTokens(ctx)
+			if err != nil {
+				return nil, err
+			}
+			buf = buf[:n]
+		}
+		d.pos = 0
+		d.end, d.err = d.r.Read(buf)
+		d.tokens -= float64(d.end)
+		if d.timeout > 0 && !d.start.IsZero() && d.clock().Sub(d.start) > d.timeout {
+			d.end, d.err = 0, ErrValueTimeout
+		}
+		if d.err != nil && d.closeReader {
+			if c, ok := d.r.(io.Closer); ok {
+				if err := c.Close(); err != nil && d.err == io.EOF {
+					d.err = err
+				}
+			}
+		}
+	}
+}
+
+// waitTokens blocks until the rate limit allows reading a chunk, returning
+// how many bytes may be read.
+func (d *Decoder) waitTokens(ctx context.Context) (int, error) {
+	// Waiting for a tenth of a second worth of bytes avoids tiny reads at
+	// low rates.
+	chunk := d.rate / 10
+	if chunk < 1 {
+		chunk = 1
+	} else if chunk > len(d.buf) {
+		chunk = len(d.buf)
+	}
+	for {
+		now := d.clock()
+		if !d.refill.IsZero() {
+			d.tokens += now.Sub(d.refill).Seconds() * float64(d.rate)
+			if d.tokens > float64(d.rate) {
+				d.tokens = float64(d.rate)
+			}
+		}
+		d.refill = now
+		if d.tokens >= float64(chunk) {
+			n := int(d.tokens)
+			if n > len(d.buf) {
+				n = len(d.buf)
+			}
+			return n, nil
+		}
+
+		wait := time.Duration((float64(chunk) - d.tokens) / float64(d.rate) * float64(time.Second))
+		if err := d.wait(ctx, wait); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (d *Decoder) clock() time.Time {
+	if d.now != nil {
+		return d.now()
+	}
+	return time.Now()
+}
+
+func (d *Decoder) wait(ctx context.Context, dur time.Duration) error {
+	if d.sleep != nil {
+		return d.sleep(ctx, dur)
+	}
+	return sleepContext(ctx, dur)
+}
+
+// sleepContext waits for d to elapse, or returns ctx's error once it is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// measure starts timing once the parser begins a value, and records its
+// duration once done is set.
+func (d *Decoder) measure(done bool) {
+	if d.start.IsZero() {
+		if !done && len(d.p.stack) == 0 {
+			return
+		}
+		d.start = d.clock()
+	}
+	if done {
+		if d.MeasureDuration {
+			d.dur = d.clock().Sub(d.start)
+		}
+		d.start = time.Time{}
+	}
+}
@@ -0,0 +1,331 @@
+package sjson
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(d *Decoder) ([]string, error) {
+	var values []string
+	for {
+		v, err := d.Next()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return values, err
+		}
+		values = append(values, string(v))
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	in := "{\"a\": 1}\n[1, 2]\n\"str\" true 12 null -3.5\n"
+	values, err := readAll(NewDecoder(iotest.OneByteReader(strings.NewReader(in))))
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"a":1}`, "[1,2]", `"str"`, "true", "12", "null", "-3.5"}, values)
+
+	values, err = readAll(NewDecoder(strings.NewReader("1 2")))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, values)
+
+	values, err = readAll(NewDecoder(strings.NewReader("   ")))
+	require.NoError(t, err)
+	assert.Empty(t, values)
+}
+
+func TestDecoderErrors(t *testing.T) {
+	values, err := readAll(NewDecoder(strings.NewReader(`{} [1,`)))
+	assert.Equal(t, []string{"{}"}, values)
+	var syntaxErr *SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+
+	boom := errors.New("boom")
+	_, err = readAll(NewDecoder(io.MultiReader(strings.NewReader("[1"), iotest.ErrReader(boom))))
+	assert.ErrorIs(t, err, boom)
+
+	d := NewDecoder(strings.NewReader(`[[[1]]]`))
+	d.Parser().MaxDepth = 2
+	_, err = d.Next()
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestDecoderDecodeNext(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`{"a": [1, "b"]} 2`))
+	v, err := d.DecodeNext()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": []any{float64(1), "b"}}, v)
+	v, err = d.DecodeNext()
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), v)
+	_, err = d.DecodeNext()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDecoderEmptyAsNull(t *testing.T) {
+	for _, in := range []string{"", " \n\t "} {
+		d := NewDecoder(strings.NewReader(in))
+		d.EmptyAsNull = true
+		v, err := d.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "null", string(v))
+		_, err = d.Next()
+		assert.Equal(t, io.EOF, err)
+
+		d = NewDecoder(strings.NewReader(in))
+		d.EmptyAsNull = true
+		dv, err := d.DecodeNext()
+		require.NoError(t, err)
+		assert.Nil(t, dv)
+	}
+
+	d := NewDecoder(strings.NewReader("1"))
+	d.EmptyAsNull = true
+	values, err := readAll(d)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, values)
+
+	d = NewDecoder(strings.NewReader("["))
+	d.EmptyAsNull = true
+	_, err = d.Next()
+	assert.Error(t, err)
+
+	_, err = NewDecoder(strings.NewReader("")).Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+// fakeClock stands for the time as seen by a Decoder, only moving forward
+// when told to.
+type fakeClock struct {
+	t time.Time
+}
+
+// newFakeClock returns a fakeClock reading a non-zero time, as the zero time
+// has a special meaning to Decoder.
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.t = c.t.Add(d)
+	return nil
+}
+
+// use makes d read the time from c.
+func (c *fakeClock) use(d *Decoder) {
+	d.now, d.sleep = c.now, c.sleep
+}
+
+// slowReader returns one chunk per Read, letting delay elapse before each of
+// them: on clock if set, and otherwise by sleeping.
+type slowReader struct {
+	chunks []string
+	delay  time.Duration
+	clock  *fakeClock
+}
+
+func (r *slowReader) Read(b []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	if r.clock != nil {
+		r.clock.t = r.clock.t.Add(r.delay)
+	} else {
+		time.Sleep(r.delay)
+	}
+	n := copy(b, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func TestDecoderMeasureDuration(t *testing.T) {
+	clock := newFakeClock()
+	r := &slowReader{chunks: []string{`[1, `, `2] `, `{}`, ` 12`}, delay: 10 * time.Millisecond, clock: clock}
+	d := NewDecoder(r)
+	clock.use(d)
+	d.MeasureDuration = true
+
+	_, err := d.Next()
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Millisecond, d.LastDuration())
+
+	_, err = d.Next()
+	require.NoError(t, err)
+	assert.Zero(t, d.LastDuration())
+
+	v, err := d.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "12", string(v))
+
+	d = NewDecoder(strings.NewReader(`[1] [2]`))
+	_, err = d.Next()
+	require.NoError(t, err)
+	assert.Zero(t, d.LastDuration())
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed int
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestNewDecoderFunc(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte("{\"a\":1}\n{\"b\":2}\n3"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	var zr *closeRecorder
+	d := NewDecoderFunc(&buf, func(r io.Reader) io.Reader {
+		gz, err := gzip.NewReader(r)
+		require.NoError(t, err)
+		zr = &closeRecorder{Reader: gz}
+		return zr
+	})
+	values, err := readAll(d)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"a":1}`, `{"b":2}`, `3`}, values)
+	assert.Equal(t, 1, zr.closed)
+
+	errBroken := errors.New("broken stream")
+	d = NewDecoderFunc(strings.NewReader("[1,"), func(r io.Reader) io.Reader {
+		return io.MultiReader(r, iotest.ErrReader(errBroken))
+	})
+	_, err = readAll(d)
+	assert.ErrorIs(t, err, errBroken)
+
+	d = NewDecoderFunc(strings.NewReader("WzEsMl0="), func(r io.Reader) io.Reader {
+		return base64.NewDecoder(base64.StdEncoding, r)
+	})
+	values, err = readAll(d)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[1,2]"}, values)
+}
+
+func TestDecoderValueTimeout(t *testing.T) {
+	// The second value begins at 20ms; 2 arrives at 40ms, within the
+	// timeout, and 3 at 60ms, past it.
+	clock := newFakeClock()
+	r := &slowReader{chunks: []string{`[1] [`, `2,`, `3]`}, delay: 20 * time.Millisecond, clock: clock}
+	d := NewDecoder(r)
+	clock.use(d)
+	d.SetValueTimeout(30 * time.Millisecond)
+
+	v, err := d.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "[1]", string(v))
+
+	_, err = d.Next()
+	assert.ErrorIs(t, err, ErrValueTimeout)
+	_, err = d.Next()
+	assert.ErrorIs(t, err, ErrValueTimeout)
+
+	r = &slowReader{chunks: []string{`[1,`, `2] [`, `3]`}, delay: 20 * time.Millisecond, clock: clock}
+	d = NewDecoder(r)
+	clock.use(d)
+	d.SetValueTimeout(30 * time.Millisecond)
+	values, err := readAll(d)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[1,2]", "[3]"}, values)
+}
+
+func TestDecoderRateLimit(t *testing.T) {
+	doc := "[" + strings.Repeat(`"abcdefgh",`, 300) + "1]"
+	clock := newFakeClock()
+	d := NewDecoder(iotest.HalfReader(strings.NewReader(doc)))
+	clock.use(d)
+	d.SetRateLimit(2000)
+	start := clock.now()
+	v, err := d.Next()
+	require.NoError(t, err)
+	assert.Equal(t, len(doc), len(v))
+	// The bucket starts full, so only the bytes beyond the first 2000 wait,
+	// at 2000 bytes per second, give or take the last chunk of 200 bytes.
+	wait := time.Duration(len(doc)-2000) * time.Second / 2000
+	assert.InDelta(t, wait, clock.now().Sub(start), float64(100*time.Millisecond))
+
+	d = NewDecoder(strings.NewReader(doc))
+	d.SetRateLimit(100)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = d.NextContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	d = NewDecoder(strings.NewReader(`[1] [2]`))
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = d.NextContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	values, err := readAll(d)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[1]", "[2]"}, values, "a cancelled context must not break the stream")
+}
+
+func TestDecoderChain(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`{"header": true} {"body": [1, 2`))
+	d.Chain(iotest.OneByteReader(strings.NewReader(`, 3]} 4`)), strings.NewReader(`5 "x`), strings.NewReader(`y"`))
+	values, err := readAll(d)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"header":true}`, `{"body":[1,2,3]}`, "45", `"xy"`}, values)
+
+	d.Chain(strings.NewReader(` [6]`))
+	values, err = readAll(d)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[6]"}, values)
+
+	d = NewDecoder(strings.NewReader(`[1`))
+	d.Chain(iotest.ErrReader(errors.New("broken")))
+	_, err = d.Next()
+	assert.EqualError(t, err, "broken")
+}
+
+func TestDecoderValueReader(t *testing.T) {
+	d := NewDecoder(strings.NewReader("{\"a\": [1, 2]}\n  \"x\" 3\n[true]"))
+	out, err := io.ReadAll(iotest.OneByteReader(d.ValueReader([]byte("\n"))))
+	require.NoError(t, err)
+	assert.Equal(t, "{\"a\":[1,2]}\n\"x\"\n3\n[true]\n", string(out))
+
+	d = NewDecoder(strings.NewReader(`{} "a" [ ]`))
+	out, err = io.ReadAll(d.ValueReader(nil))
+	require.NoError(t, err)
+	assert.Equal(t, `{}"a"[]`, string(out))
+
+	// Parser options apply to the output.
+	d = NewDecoder(strings.NewReader(`[1e3] {"n": 2}`))
+	d.Parser().NumbersAsStrings = true
+	out, err = io.ReadAll(d.ValueReader([]byte(",")))
+	require.NoError(t, err)
+	assert.Equal(t, `["1e3"],{"n":"2"},`, string(out))
+
+	d = NewDecoder(strings.NewReader(`[1] [2,]`))
+	r := d.ValueReader([]byte("\n"))
+	out, err = io.ReadAll(r)
+	assert.Equal(t, "[1]\n", string(out))
+	var syntaxErr *SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+	_, err = r.Read(make([]byte, 1))
+	assert.ErrorAs(t, err, &syntaxErr)
+}
@@ -0,0 +1,229 @@
+package sjson
+
+import "io"
+
+// DifferenceKind describes how two values differ.
+type DifferenceKind int
+
+const (
+	// Removed indicates a value present only in the first stream.
+	Removed DifferenceKind = iota
+	// Added indicates a value present only in the second stream.
+	Added
+	// Changed indicates a value present in both streams, with different
+	// contents or types.
+	Changed
+)
+
+func (k DifferenceKind) String() string {
+	switch k {
+	case Removed:
+		return "removed"
+	case Added:
+		return "added"
+	case Changed:
+		return "changed"
+	default:
+		return "invalid"
+	}
+}
+
+// Difference describes a single structural difference between two streams.
+type Difference struct {
+	// Index is the position of the top-level value in the streams.
+	Index int
+	// Path is the JSON Pointer of the differing value within the top-level
+	// value.
+	Path string
+	Kind DifferenceKind
+	// A and B hold the differing values, as returned by Unmarshal. A is nil
+	// for Added differences, and B for Removed ones.
+	A, B any
+}
+
+// DiffStreams reads values from a and b in lockstep and returns the
+// structural differences between each pair of corresponding values. Values
+// are compared through the parser's tokens: each one is held as a flat list
+// of its keys and scalars, located by their path, and only differing
+// subtrees are decoded. Object members are thus compared regardless of
+// their order, in the order they appear in a, followed by members only
+// present in b. When a stream has more values than the other, each extra
+// value is reported as a single Removed or Added difference at the top
+// level.
+func DiffStreams(a, b io.Reader) ([]Difference, error) {
+	sa, sb := newDiffStream(a), newDiffStream(b)
+	var diffs []Difference
+	for i := 0; ; i++ {
+		va, errA := sa.next()
+		if errA != nil && errA != io.EOF {
+			return diffs, errA
+		}
+		vb, errB := sb.next()
+		if errB != nil && errB != io.EOF {
+			return diffs, errB
+		}
+
+		switch {
+		case errA == io.EOF && errB == io.EOF:
+			return diffs, nil
+		case errA == io.EOF:
+			diffs = append(diffs, Difference{Index: i, Kind: Added, B: vb.value(0)})
+		case errB == io.EOF:
+			diffs = append(diffs, Difference{Index: i, Kind: Removed, A: va.value(0)})
+		default:
+			diffs = diffEntries(diffs, i, va, vb)
+		}
+	}
+}
+
+// diffEntry is a container or scalar within a top-level value, as read from
+// the parser's tokens.
+type diffEntry struct {
+	path string
+	// key is the entry's key, for object members.
+	key string
+	// tok is the token beginning the entry: a TokenBeginObject,
+	// TokenBeginArray or TokenValue.
+	tok Token
+	// end is the index of the entry following the entry's contents.
+	end int
+}
+
+// diffValue holds the entries of a top-level value, in the order they appear
+// in the input, along with their index by path.
+type diffValue struct {
+	entries []diffEntry
+	paths   map[string]int
+}
+
+// diffStream reads top-level values from a decoder as diffValues.
+type diffStream struct {
+	d      *Decoder
+	cur    diffValue
+	open   []int
+	values []diffValue
+}
+
+func newDiffStream(r io.Reader) *diffStream {
+	s := &diffStream{d: NewDecoder(r)}
+	p := s.d.Parser()
+	p.tokenFn = func(t Token) { s.token(p, t) }
+	return s
+}
+
+func (s *diffStream) token(p *Parser, t Token) {
+	switch t.Kind {
+	case TokenKey:
+		return
+	case TokenEndObject, TokenEndArray:
+		s.cur.entries[s.open[len(s.open)-1]].end = len(s.cur.entries)
+		s.open = s.open[:len(s.open)-1]
+	default:
+		segments := p.PathSegments()
+		e := diffEntry{path: pointerOf(segments), tok: t, end: len(s.cur.entries) + 1}
+		if n := len(segments); n > 0 && !segments[n-1].IsIndex() {
+			e.key = segments[n-1].Key
+		}
+		if t.Kind == TokenValue {
+			e.tok.Value = append([]byte(nil), t.Value...)
+		} else {
+			s.open = append(s.open, len(s.cur.entries))
+		}
+		if s.cur.paths == nil {
+			s.cur.paths = map[string]int{}
+		}
+		s.cur.paths[e.path] = len(s.cur.entries)
+		s.cur.entries = append(s.cur.entries, e)
+	}
+	if len(s.open) == 0 {
+		// A top-level value completed; the parser may go on with the
+		// next one before the decoder returns.
+		s.values = append(s.values, s.cur)
+		s.cur = diffValue{}
+	}
+}
+
+// next returns the next top-level value of the stream, or io.EOF once it
+// ends.
+func (s *diffStream) next() (diffValue, error) {
+	if len(s.values) == 0 {
+		if _, err := s.d.Next(); err != nil {
+			return diffValue{}, err
+		}
+	}
+	v := s.values[0]
+	s.values = s.values[1:]
+	return v, nil
+}
+
+// value decodes the entry at index i, along with its contents.
+func (v diffValue) value(i int) any {
+	e := v.entries[i]
+	switch e.tok.Kind {
+	case TokenBeginArray:
+		arr := []any{}
+		for j := i + 1; j < e.end; j = v.entries[j].end {
+			arr = append(arr, v.value(j))
+		}
+		return arr
+	case TokenBeginObject:
+		obj := map[string]any{}
+		for j := i + 1; j < e.end; j = v.entries[j].end {
+			obj[v.entries[j].key] = v.value(j)
+		}
+		return obj
+	default:
+		d := valueDecoder{data: e.tok.Value, u: &Unmarshaler{}}
+		value, _ := d.decode()
+		return value
+	}
+}
+
+// sameKind returns whether a and b are both objects, both arrays, or both
+// scalars of the same type.
+func sameKind(a, b diffEntry) bool {
+	return a.tok.Kind == b.tok.Kind && a.tok.Type == b.tok.Type
+}
+
+// diffEntries appends the differences between the top-level values a and b to
+// diffs. Members sharing a key are compared by their last occurrence, as when
+// decoded.
+func diffEntries(diffs []Difference, index int, a, b diffValue) []Difference {
+	for i := 0; i < len(a.entries); {
+		ea := a.entries[i]
+		j, ok := b.paths[ea.path]
+		switch {
+		case a.paths[ea.path] != i:
+			i = ea.end
+		case !ok:
+			diffs = append(diffs, Difference{Index: index, Path: ea.path, Kind: Removed, A: a.value(i)})
+			i = ea.end
+		case !sameKind(ea, b.entries[j]):
+			diffs = append(diffs, Difference{Index: index, Path: ea.path, Kind: Changed, A: a.value(i), B: b.value(j)})
+			i = ea.end
+		case ea.tok.Kind == TokenValue:
+			if va, vb := a.value(i), b.value(j); va != vb {
+				diffs = append(diffs, Difference{Index: index, Path: ea.path, Kind: Changed, A: va, B: vb})
+			}
+			i++
+		default:
+			i++
+		}
+	}
+	for j := 0; j < len(b.entries); {
+		eb := b.entries[j]
+		i, ok := a.paths[eb.path]
+		switch {
+		case b.paths[eb.path] != j:
+			j = eb.end
+		case !ok:
+			diffs = append(diffs, Difference{Index: index, Path: eb.path, Kind: Added, B: b.value(j)})
+			j = eb.end
+		case !sameKind(a.entries[i], eb):
+			j = eb.end
+		default:
+			j++
+		}
+	}
+	return diffs
+}
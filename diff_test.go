@@ -0,0 +1,58 @@
+package sjson
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffStreams(t *testing.T) {
+	a := `{"a": 1, "b": [1, 2, 3], "c": {"d": "x"}, "e/f": true} [1] "same"`
+	b := `{"e/f": true, "c": {"d": "y"}, "b": [1, 2], "g": null, "a": "1"} {} "same" 4`
+	diffs, err := DiffStreams(strings.NewReader(a), strings.NewReader(b))
+	require.NoError(t, err)
+	assert.Equal(t, []Difference{
+		{Index: 0, Path: "/a", Kind: Changed, A: float64(1), B: "1"},
+		{Index: 0, Path: "/b/2", Kind: Removed, A: float64(3)},
+		{Index: 0, Path: "/c/d", Kind: Changed, A: "x", B: "y"},
+		{Index: 0, Path: "/g", Kind: Added, B: nil},
+		{Index: 1, Path: "", Kind: Changed, A: []any{float64(1)}, B: map[string]any{}},
+		{Index: 3, Path: "", Kind: Added, B: float64(4)},
+	}, diffs)
+}
+
+func TestDiffStreamsIdentical(t *testing.T) {
+	diffs, err := DiffStreams(strings.NewReader(`{"a":[1,{"b":null}]}`), strings.NewReader(`{ "a" : [ 1, { "b": null } ] }`))
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+}
+
+func TestDiffStreamsDuplicateKeys(t *testing.T) {
+	// The last of the members sharing a key wins, as when decoding.
+	diffs, err := DiffStreams(strings.NewReader(`{"a":1,"a":2} {"b":{"c":1},"b":3}`), strings.NewReader(`{"a":2} {"b":{"c":2},"b":3}`))
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	diffs, err = DiffStreams(strings.NewReader(`{"a":2}`), strings.NewReader(`{"a":2,"a":[1]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []Difference{{Index: 0, Path: "/a", Kind: Changed, A: float64(2), B: []any{float64(1)}}}, diffs)
+}
+
+func TestDiffStreamsError(t *testing.T) {
+	diffs, err := DiffStreams(strings.NewReader(`1 2 [`), strings.NewReader(`1 3 []`))
+	assert.Error(t, err)
+	assert.Len(t, diffs, 1)
+}
+
+func TestDiffStreamsTokens(t *testing.T) {
+	a := `{"x": 1, "y": {"z": [true, "A"]}, "n": 1.0} 1 [2]`
+	b := `{"n": 1, "y": {"z": [true, "\u0041", null]}, "x": {"w": 1}} 1 [2]`
+	diffs, err := DiffStreams(strings.NewReader(a), strings.NewReader(b))
+	require.NoError(t, err)
+	assert.Equal(t, []Difference{
+		{Index: 0, Path: "/x", Kind: Changed, A: float64(1), B: map[string]any{"w": float64(1)}},
+		{Index: 0, Path: "/y/z/2", Kind: Added, B: nil},
+	}, diffs)
+}
@@ -0,0 +1,98 @@
+package sjson
+
+import (
+	"hash/maphash"
+	"math"
+	"math/bits"
+)
+
+// distinctPrecision is the number of hash bits selecting a register of a
+// keyEstimator.
+const distinctPrecision = 10
+
+const distinctRegisters = 1 << distinctPrecision
+
+// distinctExact is how many distinct keys a keyEstimator counts exactly,
+// before falling back to its estimate.
+const distinctExact = 256
+
+// distinctSeed seeds the hash of keys counted for MaxDistinctKeys. It is
+// random, so adversarial inputs can't be crafted to fool the estimate.
+var distinctSeed = maphash.MakeSeed()
+
+// keyEstimator counts the distinct keys added to it. The hashes of the first
+// distinctExact keys are kept, so small counts are exact; past that, the
+// count is estimated using HyperLogLog with a fixed amount of memory.
+type keyEstimator struct {
+	// exact holds the hashes of the keys added, until there are more than
+	// distinctExact of them; it is nil from then on.
+	exact     map[uint64]struct{}
+	registers [distinctRegisters]uint8
+	// sum holds the sum of 2^-r over all registers r, and zeros the number
+	// of registers still zero, kept up to date so estimates are cheap.
+	sum   float64
+	zeros int
+}
+
+func (e *keyEstimator) reset() {
+	if e.exact == nil {
+		e.exact = make(map[uint64]struct{}, distinctExact+1)
+	}
+	for h := range e.exact {
+		delete(e.exact, h)
+	}
+	e.registers = [distinctRegisters]uint8{}
+	e.sum = distinctRegisters
+	e.zeros = distinctRegisters
+}
+
+func (e *keyEstimator) add(key string) {
+	h := maphash.String(distinctSeed, key)
+	if e.exact != nil {
+		e.exact[h] = struct{}{}
+		if len(e.exact) > distinctExact {
+			e.exact = nil
+		}
+	}
+	idx := h >> (64 - distinctPrecision)
+	rank := uint8(bits.LeadingZeros64(h<<distinctPrecision|1<<(distinctPrecision-1)) + 1)
+	old := e.registers[idx]
+	if rank <= old {
+		return
+	}
+	if old == 0 {
+		e.zeros--
+	}
+	e.sum += math.Ldexp(1, -int(rank)) - math.Ldexp(1, -int(old))
+	e.registers[idx] = rank
+}
+
+// estimate returns the number of distinct keys added: exactly, as long as
+// there are at most distinctExact of them, and otherwise estimated and
+// rounded to the nearest integer.
+func (e *keyEstimator) estimate() int {
+	if e.exact != nil {
+		return len(e.exact)
+	}
+	const m = float64(distinctRegisters)
+	est := 0.7213 / (1 + 1.079/m) * m * m / e.sum
+	if est <= 2.5*m && e.zeros > 0 {
+		// Linear counting is more accurate for small cardinalities.
+		est = m * math.Log(m/float64(e.zeros))
+	}
+	return int(math.Round(est))
+}
+
+// countDistinctKey adds key to the estimate of distinct keys held by the
+// current top-level value, failing once it exceeds the MaxDistinctKeys limit.
+func (p *Parser) countDistinctKey(key string) error {
+	if p.distinct == nil {
+		p.distinct = &keyEstimator{}
+		p.distinct.reset()
+	}
+	p.distinct.add(key)
+	if max := p.limits().MaxDistinctKeys; p.distinct.estimate() > max {
+		return p.limit("MaxDistinctKeys", max)
+	}
+	return nil
+}
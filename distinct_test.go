@@ -0,0 +1,67 @@
+package sjson
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxDistinctKeys(t *testing.T) {
+	_, err := parseSingle(&Parser{MaxDistinctKeys: 3}, []byte(`{"a": 1, "b": {"\u0061": 2, "c": [{"b": 3, "a": 4}]}}`))
+	require.NoError(t, err)
+
+	_, err = parseSingle(&Parser{MaxDistinctKeys: 3}, []byte(`{"a": 1, "b": {"a": 2, "c": [{"d": 3}]}}`))
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "MaxDistinctKeys", limitErr.Limit)
+	assert.Equal(t, uint64(32), limitErr.Offset)
+
+	// The count starts over with each top-level value.
+	p := &Parser{MaxDistinctKeys: 2}
+	_, err = feedAll(p, `{"a": 1, "b": 2} {"c": 1, "d": {"c": 2}} [{"e": 1}, {"f": 2}]`)
+	require.NoError(t, err)
+	_, err = feedAll(p, `[{"e": 1}, {"f": 2}, {"g": 3}]`)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestMaxDistinctKeysLargeObjects(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i < 5000; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `"key%d": {"key%d": 1}`, i, i)
+	}
+	sb.WriteByte('}')
+	data := []byte(sb.String())
+
+	_, err := parseSingle(&Parser{MaxDistinctKeys: 6000}, data)
+	require.NoError(t, err)
+	_, err = parseSingle(&Parser{MaxDistinctKeys: 4000}, data)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestKeyEstimator(t *testing.T) {
+	var e keyEstimator
+	for _, n := range []int{1, 2, 3, 4, 100, distinctExact} {
+		e.reset()
+		for i := 0; i < n; i++ {
+			e.add(fmt.Sprintf("k%d", i))
+			e.add(fmt.Sprintf("k%d", i/2))
+		}
+		assert.Equal(t, n, e.estimate(), "%d keys", n)
+	}
+
+	for _, n := range []int{1000, 10000, 100000} {
+		e.reset()
+		for i := 0; i < n; i++ {
+			e.add(fmt.Sprintf("k%d", i))
+			e.add(fmt.Sprintf("k%d", i/2))
+		}
+		assert.InEpsilon(t, n, e.estimate(), 0.15, "%d keys", n)
+	}
+}
@@ -0,0 +1,81 @@
+package sjson
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SyntaxError is returned when the parser is fed malformed input.
+type SyntaxError struct {
+	// Msg describes the problem found.
+	Msg string
+	// Offset is the position of the offending byte in the input stream.
+	Offset uint64
+	// Key holds the quoted object key the error refers to, if any, as found
+	// in the input. For duplicate keys, Offset points to its first byte.
+	Key []byte
+
+	format func(e SyntaxError) string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.format != nil {
+		return e.format(*e)
+	}
+	return fmt.Sprintf("failed parsing stream: %s at position %d", e.Msg, e.Offset)
+}
+
+// ErrLimitExceeded is matched by errors.Is for every error caused by the
+// input exceeding one of the limits configured in a Parser. Those errors are
+// never SyntaxErrors, as the input may well be valid JSON.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// ErrValueTimeout is returned by a Decoder when a value takes longer to arrive
+// than the timeout set with SetValueTimeout.
+var ErrValueTimeout = errors.New("failed parsing stream: value timed out")
+
+// LimitError is returned when the input exceeds one of the limits configured
+// in a Parser.
+type LimitError struct {
+	// Limit is the name of the Parser field holding the exceeded limit.
+	Limit string
+	// Max is the configured value of the limit.
+	Max int
+	// Offset is the position of the offending byte in the input stream.
+	Offset uint64
+	// Path is the JSON Pointer the limit was set for, if it was scoped to
+	// one with SetMaxDepthAt.
+	Path string
+}
+
+func (e *LimitError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("failed parsing stream: %s of %d at %q exceeded at position %d", e.Limit, e.Max, e.Path, e.Offset)
+	}
+	return fmt.Sprintf("failed parsing stream: %s of %d exceeded at position %d", e.Limit, e.Max, e.Offset)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// ValidationError is returned when a validator registered with ValidateAt
+// rejects a value.
+type ValidationError struct {
+	// Path is the JSON Pointer of the rejected value.
+	Path string
+	// Offset is the position in the input stream where the value was
+	// found invalid: where it was completed, or where it began for schema
+	// type mismatches.
+	Offset uint64
+	// Err is the error returned by the validator.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("failed parsing stream: invalid value at %q, position %d: %s", e.Path, e.Offset, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
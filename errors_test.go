@@ -0,0 +1,159 @@
+package sjson
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyntaxError(t *testing.T) {
+	_, err := Parse([]byte(`[1, x]`))
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, uint64(4), syntaxErr.Offset)
+	assert.NotErrorIs(t, err, ErrLimitExceeded)
+
+	_, err = Parse([]byte(`[1,`))
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, "unexpected end of input", syntaxErr.Msg)
+}
+
+func TestLimitErrors(t *testing.T) {
+	tests := []struct {
+		parser Parser
+		input  string
+		limit  string
+	}{
+		{Parser{MaxDepth: 2}, "[[[1]]]", "MaxDepth"},
+		{Parser{MaxDepth: 2}, `{"a":{"b":[]}}`, "MaxDepth"},
+		{Parser{MaxStringLen: 3}, `["abcd"]`, "MaxStringLen"},
+		{Parser{MaxStringLen: 3}, `{"abcd":1}`, "MaxStringLen"},
+		{Parser{MaxKeyLen: 3}, `{"abcd":1}`, "MaxKeyLen"},
+		{Parser{MaxKeyLen: 3, MaxStringLen: 10}, `{"a":{"abcd":1}}`, "MaxKeyLen"},
+		{Parser{MaxKeyLen: 10, MaxStringLen: 3}, `{"abcd":"abcd"}`, "MaxStringLen"},
+		{Parser{MaxNumberLen: 3}, "[1234]", "MaxNumberLen"},
+		{Parser{MaxNumberLen: 3}, "[-1.5e3]", "MaxNumberLen"},
+		{Parser{MaxNumberLen: 3}, "1234", "MaxNumberLen"},
+		{Parser{MaxValueBytes: 5}, `[1,2,3]`, "MaxValueBytes"},
+		{Parser{MaxConsecutiveWhitespace: 1}, "[1,  2]", "MaxConsecutiveWhitespace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.limit+" "+tt.input, func(t *testing.T) {
+			p := tt.parser
+			_, err := parseSingle(&p, []byte(tt.input))
+			require.Error(t, err)
+			assert.ErrorIs(t, err, ErrLimitExceeded)
+			var limitErr *LimitError
+			require.ErrorAs(t, err, &limitErr)
+			assert.Equal(t, tt.limit, limitErr.Limit)
+			var syntaxErr *SyntaxError
+			assert.False(t, errors.As(err, &syntaxErr))
+		})
+	}
+
+	// Numbers are rejected at their first byte past the limit.
+	for in, offset := range map[string]uint64{"[12345678]": 4, "12345678": 3, "[-1.5e3]": 4} {
+		_, err := parseSingle(&Parser{MaxNumberLen: 3}, []byte(in))
+		var limitErr *LimitError
+		require.ErrorAs(t, err, &limitErr, in)
+		assert.Equal(t, offset, limitErr.Offset, in)
+	}
+
+	within := []struct {
+		parser Parser
+		input  string
+	}{
+		{Parser{MaxDepth: 2}, "[[1],[2]]"},
+		{Parser{MaxStringLen: 3}, `{"abc":"def"}`},
+		{Parser{MaxKeyLen: 3}, `{"abc":"defghi"}`},
+		{Parser{MaxKeyLen: 6, MaxStringLen: 3}, `{"abcdef":"ghi"}`},
+		{Parser{MaxNumberLen: 3}, "[123,-12]"},
+		{Parser{MaxNumberLen: 3}, "123"},
+		{Parser{MaxValueBytes: 7}, `[1,2,3]`},
+	}
+	for _, tt := range within {
+		p := tt.parser
+		_, err := parseSingle(&p, []byte(tt.input))
+		assert.NoError(t, err, tt.input)
+	}
+}
+
+func TestExpectedValueMessages(t *testing.T) {
+	tests := map[string]string{
+		`x`:                  "expected a JSON value, got `x'",
+		`[1, x]`:             "expected an array element, got `x'",
+		`[x]`:                "expected an array element, got `x'",
+		`{"a": x}`:           "expected a value after ':' in object, got `x'",
+		`{"a": [{"b": +1}]}`: "expected a value after ':' in object, got `+'",
+	}
+	for in, msg := range tests {
+		_, err := Parse([]byte(in))
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, in)
+		assert.Equal(t, msg, syntaxErr.Msg, in)
+	}
+}
+
+func TestMismatchedBrackets(t *testing.T) {
+	cases := map[string]struct {
+		msg    string
+		offset uint64
+	}{
+		"n_structure_array_closed_with_brace.json":    {"mismatched bracket: found `}' in an array, expected ']'", 5},
+		"n_structure_object_closed_with_bracket.json": {"mismatched bracket: found `]' in an object, expected '}'", 6},
+		"n_structure_open_object_close_array.json":    {"mismatched bracket: found `]' in an object, expected '}'", 1},
+	}
+	for name, want := range cases {
+		data, err := os.ReadFile("fixtures/" + name)
+		require.NoError(t, err)
+		_, err = Parse(data)
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, name)
+		assert.Equal(t, want.msg, syntaxErr.Msg, name)
+		assert.Equal(t, want.offset, syntaxErr.Offset, name)
+	}
+
+	for in, msg := range map[string]string{
+		`[}`:           "mismatched bracket: found `}' in an array, expected ']'",
+		`{"a": [1 }}`:  "mismatched bracket: found `}' in an array, expected ']'",
+		`[{"a": "b"]]`: "mismatched bracket: found `]' in an object, expected '}'",
+		`{"a": {} ]`:   "mismatched bracket: found `]' in an object, expected '}'",
+		`[[true}]`:     "mismatched bracket: found `}' in an array, expected ']'",
+	} {
+		_, err := Parse([]byte(in))
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, in)
+		assert.Equal(t, msg, syntaxErr.Msg, in)
+	}
+}
+
+func TestEmptyInput(t *testing.T) {
+	for _, in := range []string{"", "   ", " \t\r\n ", "\n\n"} {
+		for name, parse := range map[string]func([]byte) ([]byte, error){
+			"Parse":       Parse,
+			"ParseReader": func(data []byte) ([]byte, error) { return ParseReader(bytes.NewReader(data)) },
+			"ParseInto":   func(data []byte) ([]byte, error) { return ParseInto(nil, data) },
+			"SkipBOM": func(data []byte) ([]byte, error) {
+				return parseSingle(&Parser{SkipBOM: true}, append([]byte("\xEF\xBB\xBF"), data...))
+			},
+		} {
+			v, err := parse([]byte(in))
+			assert.Nil(t, v, "%s %q", name, in)
+			var syntaxErr *SyntaxError
+			require.ErrorAs(t, err, &syntaxErr, "%s %q", name, in)
+			assert.Equal(t, "empty input, expected a JSON value", syntaxErr.Msg, "%s %q", name, in)
+		}
+
+		_, err := Unmarshal([]byte(in))
+		assert.ErrorContains(t, err, "empty input", "%q", in)
+		_, err = Tokens([]byte(in))
+		assert.ErrorContains(t, err, "empty input", "%q", in)
+	}
+
+	_, err := Parse([]byte(" [1, "))
+	assert.ErrorContains(t, err, "unexpected end of input")
+}
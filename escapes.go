@@ -0,0 +1,231 @@
+package sjson
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// EscapeDecoder decodes a non-standard escape sequence registered with
+// RegisterEscape, given the hexadecimal digits following its character, into
+// the text it stands for.
+type EscapeDecoder func(digits []byte) (string, error)
+
+// customEscape is an escape sequence registered with RegisterEscape.
+type customEscape struct {
+	digits int
+	decode EscapeDecoder
+}
+
+// escapeSet holds the non-standard escapes resolved by unescapeString.
+type escapeSet struct {
+	// hex resolves \xHH into the code point U+00HH.
+	hex    bool
+	custom map[byte]customEscape
+}
+
+// RegisterEscape makes ValidateEscapes accept the non-standard escape
+// sequence made of a backslash, char, and the given number of hexadecimal
+// digits, which may be zero. For instance, RegisterEscape('x', 2, nil)
+// accepts \x41, and RegisterEscape('v', 0, nil) accepts \v. Escapes defined
+// by JSON can't be redefined. When decode is not nil, Unescape and
+// NormalizeEscapes use it to resolve the escape; otherwise it is only
+// validated.
+func (p *Parser) RegisterEscape(char byte, digits int, decode EscapeDecoder) {
+	if isStandardEscape(char) {
+		return
+	}
+	if p.escapes == nil {
+		p.escapes = map[byte]customEscape{}
+	}
+	p.escapes[char] = customEscape{digits: digits, decode: decode}
+}
+
+// Unescape is like UnescapeString, but also resolves \xHH when
+// AllowHexEscapes is set, and escapes registered with a decoder through
+// RegisterEscape.
+func (p *Parser) Unescape(raw []byte) (string, error) {
+	return unescapeString(raw, p.escapeSet())
+}
+
+func (p *Parser) escapeSet() escapeSet {
+	return escapeSet{hex: p.AllowHexEscapes, custom: p.escapes}
+}
+
+// resolve appends the text of the non-standard escape whose character is at
+// raw[i] to out, returning it along with the index of the escape's last
+// byte.
+func (s escapeSet) resolve(out, raw []byte, i int) ([]byte, int, error) {
+	c := raw[i]
+	if c == 'x' && s.hex {
+		hi, ok1 := hexValue(byteAt(raw, i+1))
+		lo, ok2 := hexValue(byteAt(raw, i+2))
+		if !ok1 || !ok2 {
+			return nil, 0, fmt.Errorf("invalid string: invalid \\x escape")
+		}
+		return utf8.AppendRune(out, hi<<4|lo), i + 2, nil
+	}
+	e, ok := s.custom[c]
+	if !ok || e.decode == nil {
+		return nil, 0, fmt.Errorf("invalid string: invalid escape `\\%c'", c)
+	}
+	end := i + e.digits
+	if end >= len(raw) {
+		return nil, 0, fmt.Errorf("invalid string: invalid \\%c escape", c)
+	}
+	for _, d := range raw[i+1 : end+1] {
+		if _, ok := hexValue(d); !ok {
+			return nil, 0, fmt.Errorf("invalid string: invalid \\%c escape", c)
+		}
+	}
+	text, err := e.decode(raw[i+1 : end+1])
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid string: invalid \\%c escape: %w", c, err)
+	}
+	return append(out, text...), end, nil
+}
+
+func isStandardEscape(b byte) bool {
+	switch b {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't', 'u':
+		return true
+	}
+	return false
+}
+
+// escapeDigits returns how many hexadecimal digits follow the non-standard
+// escape character b, and whether it is accepted at all.
+func (p *Parser) escapeDigits(b byte) (int, bool) {
+	if b == 'x' && p.AllowHexEscapes {
+		return 2, true
+	}
+	e, ok := p.escapes[b]
+	return e.digits, ok
+}
+
+// checkEscape validates the escape sequences of the string being parsed, b
+// being its next byte. It must be called before p.escaped is updated.
+func (p *Parser) checkEscape(b byte) error {
+	switch {
+	case p.hexLeft > 0:
+		d, ok := hexValue(b)
+		if !ok {
+			return p.fail("invalid hexadecimal digit '%c' in \\%c escape", b, p.escChar)
+		}
+		p.escRune = p.escRune<<4 | d
+		if p.hexLeft--; p.hexLeft == 0 && p.escChar == 'u' {
+			return p.unicodeEscaped()
+		}
+	case p.escaped:
+		if b == 'u' {
+			p.hexLeft, p.escChar, p.escRune = 4, b, 0
+			return nil
+		}
+		if !isStandardEscape(b) {
+			n, ok := p.escapeDigits(b)
+			if !ok {
+				return p.fail("invalid escape sequence '\\%c'", b)
+			}
+			if p.NormalizeEscapes && !(b == 'x' && p.AllowHexEscapes) && p.escapes[b].decode == nil {
+				return p.fail("escape sequence '\\%c' has no decoder to normalize it", b)
+			}
+			p.hexLeft, p.escChar, p.escRune = n, b, 0
+		}
+		if p.lowPending {
+			return p.fail("unpaired surrogate in \\u escape")
+		}
+	case p.lowPending && b != '\\':
+		return p.fail("unpaired surrogate in \\u escape")
+	}
+	return nil
+}
+
+// unicodeEscaped checks the code unit of the \u escape just read.
+func (p *Parser) unicodeEscaped() error {
+	if !p.ValidateEscapes {
+		// Surrogates are only checked by ValidateEscapes; NormalizeEscapes
+		// replaces unpaired ones.
+		return nil
+	}
+	r := p.escRune
+	switch {
+	case p.lowPending:
+		if r < 0xDC00 || r > 0xDFFF {
+			return p.fail("unpaired surrogate in \\u escape")
+		}
+		p.lowPending = false
+	case r >= 0xD800 && r <= 0xDBFF:
+		p.lowPending = true
+	case r >= 0xDC00 && r <= 0xDFFF:
+		return p.fail("unpaired surrogate in \\u escape")
+	}
+	return nil
+}
+
+func hexValue(b byte) (rune, bool) {
+	switch {
+	case b >= '0' && b <= '9':
+		return rune(b - '0'), true
+	case b >= 'a' && b <= 'f':
+		return rune(b - 'a' + 10), true
+	case b >= 'A' && b <= 'F':
+		return rune(b - 'A' + 10), true
+	}
+	return 0, false
+}
+
+// checkUTF8 validates the raw bytes of the string being parsed, b being its
+// next byte. Overlong encodings, surrogates and code points above U+10FFFF
+// are rejected, as done by utf8.Valid.
+func (p *Parser) checkUTF8(b byte) error {
+	if p.utf8Left > 0 {
+		if b < p.utf8Lo || b > p.utf8Hi {
+			return p.fail("invalid UTF-8 in string")
+		}
+		p.utf8Left--
+		p.utf8Lo, p.utf8Hi = 0x80, 0xBF
+		return nil
+	}
+	p.utf8Lo, p.utf8Hi = 0x80, 0xBF
+	switch {
+	case b < 0x80:
+	case b >= 0xC2 && b <= 0xDF:
+		p.utf8Left = 1
+	case b == 0xE0:
+		p.utf8Left, p.utf8Lo = 2, 0xA0
+	case b == 0xED:
+		p.utf8Left, p.utf8Hi = 2, 0x9F
+	case b >= 0xE1 && b <= 0xEF:
+		p.utf8Left = 2
+	case b == 0xF0:
+		p.utf8Left, p.utf8Lo = 3, 0x90
+	case b >= 0xF1 && b <= 0xF3:
+		p.utf8Left = 3
+	case b == 0xF4:
+		p.utf8Left, p.utf8Hi = 3, 0x8F
+	default:
+		return p.fail("invalid UTF-8 in string")
+	}
+	return nil
+}
+
+// normalizeEscapes rewrites the string just read, starting at position start
+// of data, with the escaping used by Canonicalize, for NormalizeEscapes.
+func (p *Parser) normalizeEscapes(start int) error {
+	raw := p.data[start:]
+	plain := true
+	for _, c := range raw[1 : len(raw)-1] {
+		if c == '\\' || c < 0x20 {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return nil
+	}
+	s, err := unescapeString(raw, p.escapeSet())
+	if err != nil {
+		return p.fail("%s", err)
+	}
+	p.data = appendCanonicalString(p.data[:start], s)
+	return nil
+}
@@ -0,0 +1,262 @@
+package sjson
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateEscapes(t *testing.T) {
+	for _, utf8 := range []bool{false, true} {
+		optionFixtures(t, "fixtures/validate_escapes", func() *Parser {
+			return &Parser{ValidateEscapes: true, ValidateUTF8: utf8}
+		}, "unpaired surrogate", true)
+	}
+
+	invalid := map[string]uint64{
+		`["\x"]`:       3,
+		`["ab\u12G4"]`: 8,
+		`["\u12"]`:     6,
+		`{"\q": 1}`:    3,
+		`["\uD800A"]`:  8,
+		`["\uDFFF"]`:   7,
+		`["\uD800"]`:   8,
+	}
+	for in, offset := range invalid {
+		_, err := parseSingle(&Parser{ValidateEscapes: true}, []byte(in))
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, in)
+		assert.Equal(t, offset, syntaxErr.Offset, in)
+		_, err = Parse([]byte(in))
+		assert.NoError(t, err, in)
+	}
+}
+
+func TestInvalidHexDigits(t *testing.T) {
+	optionFixtures(t, "fixtures/hex_escapes", func() *Parser {
+		return &Parser{ValidateEscapes: true}
+	}, "invalid hexadecimal digit 'G' in \\u escape", true)
+
+	// Errors are reported at the offending digit, whatever its position.
+	for i := 1; i <= 4; i++ {
+		name := fmt.Sprintf("fixtures/hex_escapes/n_bad_hex_digit_%d.json", i)
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		p := &Parser{ValidateEscapes: true}
+		for _, b := range data {
+			if _, err = p.Feed(b); err != nil {
+				break
+			}
+		}
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, name)
+		assert.Equal(t, uint64(bytes.IndexByte(data, 'G')), syntaxErr.Offset, name)
+		snippet := p.ContextSnippet(8)
+		assert.Equal(t, byte('G'), snippet[len(snippet)-1], name)
+	}
+}
+
+func TestValidateUTF8(t *testing.T) {
+	invalid := []string{
+		"[\"\xff\"]",
+		"[\"\xc3\"]",
+		"[\"\xc0\xaf\"]",
+		"[\"\xe0\x80\xaf\"]",
+		"[\"\xed\xa0\x80\"]",
+		"[\"\xf4\x90\x80\x80\"]",
+		"{\"a\x80\": 1}",
+	}
+	for _, in := range invalid {
+		_, err := parseSingle(&Parser{ValidateUTF8: true}, []byte(in))
+		assert.ErrorContains(t, err, "invalid UTF-8 in string", "%q", in)
+		_, err = parseSingle(&Parser{ValidateEscapes: true}, []byte(in))
+		assert.NoError(t, err, "%q", in)
+	}
+
+	out, err := parseSingle(&Parser{ValidateUTF8: true}, []byte(`["é€𝄞", "\uD800"]`))
+	require.NoError(t, err)
+	assert.Equal(t, `["é€𝄞","\uD800"]`, string(out))
+}
+
+func TestStringValidationFixtures(t *testing.T) {
+	fixtures, err := os.ReadDir("fixtures")
+	require.NoError(t, err)
+	rejected := map[string]bool{
+		"i_object_key_lone_2nd_surrogate.json":                true,
+		"i_string_1st_surrogate_but_2nd_missing.json":         true,
+		"i_string_1st_valid_surrogate_2nd_invalid.json":       true,
+		"i_string_UTF-8_invalid_sequence.json":                true,
+		"i_string_UTF8_surrogate_U+D800.json":                 true,
+		"i_string_incomplete_surrogate_and_escape_valid.json": true,
+		"i_string_incomplete_surrogate_pair.json":             true,
+		"i_string_incomplete_surrogates_escape_valid.json":    true,
+		"i_string_invalid_lonely_surrogate.json":              true,
+		"i_string_invalid_surrogate.json":                     true,
+		"i_string_invalid_utf-8.json":                         true,
+		"i_string_inverted_surrogates_U+1D11E.json":           true,
+		"i_string_iso_latin_1.json":                           true,
+		"i_string_lone_second_surrogate.json":                 true,
+		"i_string_lone_utf8_continuation_byte.json":           true,
+		"i_string_not_in_unicode_range.json":                  true,
+		"i_string_overlong_sequence_2_bytes.json":             true,
+		"i_string_overlong_sequence_6_bytes.json":             true,
+		"i_string_overlong_sequence_6_bytes_null.json":        true,
+		"i_string_truncated-utf-8.json":                       true,
+	}
+	for _, f := range fixtures {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		data, err := os.ReadFile("fixtures/" + name)
+		require.NoError(t, err)
+		_, defaultErr := Parse(data)
+		_, err = parseSingle(&Parser{ValidateEscapes: true, ValidateUTF8: true}, data)
+		switch {
+		case rejected[name]:
+			assert.Error(t, err, name)
+		case strings.HasPrefix(name, "y_"), strings.HasPrefix(name, "i_") && defaultErr == nil:
+			assert.NoError(t, err, name)
+		}
+	}
+}
+
+func TestRegisterEscape(t *testing.T) {
+	in := []byte(`["\x41\v", {"\U0001F600": "é"}]`)
+	_, err := parseSingle(&Parser{ValidateEscapes: true}, in)
+	assert.ErrorContains(t, err, `invalid escape sequence '\x'`)
+
+	p := &Parser{ValidateEscapes: true, AllowHexEscapes: true}
+	p.RegisterEscape('v', 0, nil)
+	p.RegisterEscape('U', 8, nil)
+	p.RegisterEscape('u', 0, nil)
+	out, err := parseSingle(p, in)
+	require.NoError(t, err)
+	assert.Equal(t, `["\x41\v",{"\U0001F600":"é"}]`, string(out))
+
+	for in, msg := range map[string]string{
+		`["\x4"]`:        `invalid hexadecimal digit '"' in \x escape`,
+		`["\xG1"]`:       `invalid hexadecimal digit 'G' in \x escape`,
+		`["\U0001F60"]`:  `invalid hexadecimal digit '"' in \U escape`,
+		`["\uD800\x41"]`: "unpaired surrogate",
+		`["\a"]`:         `invalid escape sequence '\a'`,
+	} {
+		p.Reset()
+		_, err := parseSingle(p, []byte(in))
+		assert.ErrorContains(t, err, msg, in)
+	}
+}
+
+func TestRegisterEscapeDecoder(t *testing.T) {
+	decodeU := func(digits []byte) (string, error) {
+		r, err := strconv.ParseUint(string(digits), 16, 32)
+		if err != nil || r > unicode.MaxRune {
+			return "", fmt.Errorf("code point %s out of range", digits)
+		}
+		return string(rune(r)), nil
+	}
+	decodeV := func([]byte) (string, error) { return "\v", nil }
+
+	p := &Parser{ValidateEscapes: true}
+	p.RegisterEscape('U', 8, decodeU)
+	p.RegisterEscape('v', 0, decodeV)
+	s, err := p.Unescape([]byte(`"\U0001F600 \v \n"`))
+	require.NoError(t, err)
+	assert.Equal(t, "\U0001F600 \v \n", s)
+	_, err = p.Unescape([]byte(`"\UFFFFFFFF"`))
+	assert.ErrorContains(t, err, "code point FFFFFFFF out of range")
+	_, err = UnescapeString([]byte(`"\v"`))
+	assert.Error(t, err)
+
+	p.NormalizeEscapes = true
+	out, err := parseSingle(p, []byte(`{"\U00000041": "\v"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"A":"\u000b"}`, string(out))
+
+	// Escapes registered without a decoder are only validated.
+	p = &Parser{ValidateEscapes: true, NormalizeEscapes: true}
+	p.RegisterEscape('v', 0, nil)
+	_, err = parseSingle(p, []byte(`"\v"`))
+	assert.ErrorContains(t, err, "has no decoder to normalize it at position 2")
+
+	u := &Unmarshaler{}
+	u.RegisterEscape('U', 8, decodeU)
+	v, err := u.Unmarshal([]byte(`["\U0001F600", "\U0001F60"]`))
+	assert.Error(t, err)
+	assert.Nil(t, v)
+	v, err = u.Unmarshal([]byte(`{"\U00000041": "\U0001F600"}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"A": "\U0001F600"}, v)
+}
+
+func TestUnmarshalHexEscapes(t *testing.T) {
+	_, err := Unmarshal([]byte(`"\x41"`))
+	assert.Error(t, err)
+
+	v, err := (&Unmarshaler{AllowHexEscapes: true}).Unmarshal([]byte(`["\x41\xe9\\x", "\x4"]`))
+	assert.Error(t, err)
+	assert.Nil(t, v)
+
+	v, err = (&Unmarshaler{AllowHexEscapes: true}).Unmarshal([]byte(`["\x41\xe9\\x"]`))
+	require.NoError(t, err)
+	assert.Equal(t, []any{"Aé\\x"}, v)
+}
+
+func TestNormalizeEscapes(t *testing.T) {
+	for in, want := range map[string]string{
+		`"\u0041"`:                `"A"`,
+		`"\ud83d\ude00!"`:         `"` + "\U0001F600" + `!"`,
+		`"\/a\/"`:                 `"/a/"`,
+		`"caf\u00e9"`:             `"café"`,
+		`"a\"b\\c"`:               `"a\"b\\c"`,
+		`"\u0022\u005c"`:          `"\"\\"`,
+		`"\u001F\u0008\n\t"`:      `"\u001f\b\n\t"`,
+		`"\ud800"`:                `"` + "\uFFFD" + `"`,
+		`"plain"`:                 `"plain"`,
+		`{"\u0061":"\u0062"}`:     `{"a":"b"}`,
+		`["\u0041", {"\/": [1]}]`: `["A",{"/":[1]}]`,
+	} {
+		v, err := parseSingle(&Parser{NormalizeEscapes: true}, []byte(in))
+		require.NoError(t, err, in)
+		assert.Equal(t, want, string(v), in)
+	}
+
+	p := &Parser{NormalizeEscapes: true}
+	var keys []string
+	p.SetKeyCallback(func(key []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	_, err := feedAll(p, `{"\u0061b": {"\u0063": 1}}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`"ab"`, `"c"`}, keys)
+
+	p = &Parser{NormalizeEscapes: true, AllowUnescapedNewlinesInStrings: true}
+	v, err := feedAll(p, "\"a\nb\" ")
+	require.NoError(t, err)
+	assert.Equal(t, `"a\nb"`, string(v))
+
+	p = &Parser{NormalizeEscapes: true, AllowHexEscapes: true}
+	v, err = feedAll(p, `"\x41" `)
+	require.NoError(t, err)
+	assert.Equal(t, `"A"`, string(v))
+
+	// Malformed escapes are reported at their offending byte, within the
+	// stream.
+	for in, want := range map[string]string{
+		`["ab\q"]`:      `invalid escape sequence '\q' at position 5`,
+		`[1, "\u12G4"]`: `invalid hexadecimal digit 'G' in \u escape at position 9`,
+	} {
+		_, err = parseSingle(&Parser{NormalizeEscapes: true}, []byte(in))
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, in)
+		assert.EqualError(t, err, "failed parsing stream: "+want, in)
+	}
+}
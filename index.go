@@ -0,0 +1,57 @@
+package sjson
+
+import "io"
+
+// indexChunkSize is how many bytes IndexValues reads at a time.
+const indexChunkSize = 64 << 10
+
+// ValueSpan locates a top-level value within its input.
+type ValueSpan struct {
+	// Start and End are the offsets of the value's first byte and of the
+	// byte following its last one, so the value can be read back with
+	// io.NewSectionReader(r, Start, End-Start).
+	Start, End int64
+}
+
+// IndexValues reads the size bytes of r, holding any number of top-level
+// values, such as an NDJSON file, and returns the span of each of them, in
+// order. r is read in chunks and values are only validated, not buffered,
+// so memory use only grows with the nesting depth of the values, and files
+// of any size can be indexed. If r holds malformed data, the spans of the
+// values preceding the error are returned along with it.
+func IndexValues(r io.ReaderAt, size int64) ([]ValueSpan, error) {
+	var spans []ValueSpan
+	p := &Parser{discard: true}
+	add := func() {
+		start, end := p.LastValueSpan()
+		spans = append(spans, ValueSpan{Start: int64(start), End: int64(end)})
+	}
+
+	buf := make([]byte, indexChunkSize)
+	sr := io.NewSectionReader(r, 0, size)
+	for {
+		n, err := sr.Read(buf)
+		for _, b := range buf[:n] {
+			v, fErr := p.Feed(b)
+			if fErr != nil {
+				return spans, fErr
+			}
+			if v != nil {
+				add()
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return spans, err
+		}
+	}
+	if len(p.stack) > 0 {
+		if _, err := p.finish(); err != nil {
+			return spans, err
+		}
+		add()
+	}
+	return spans, nil
+}
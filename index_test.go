@@ -0,0 +1,74 @@
+package sjson
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexValues(t *testing.T) {
+	data := "{\"a\": [1, 2]}\n\"x\"\n\n  true\n-1.5"
+	r := strings.NewReader(data)
+	spans, err := IndexValues(r, int64(len(data)))
+	require.NoError(t, err)
+	var values []string
+	for _, s := range spans {
+		v, err := io.ReadAll(io.NewSectionReader(r, s.Start, s.End-s.Start))
+		require.NoError(t, err)
+		values = append(values, string(v))
+	}
+	assert.Equal(t, []string{`{"a": [1, 2]}`, `"x"`, `true`, `-1.5`}, values)
+
+	// Only size bytes are read.
+	spans, err = IndexValues(r, 20)
+	require.NoError(t, err)
+	assert.Equal(t, []ValueSpan{{0, 13}, {14, 17}}, spans)
+
+	spans, err = IndexValues(strings.NewReader(""), 0)
+	require.NoError(t, err)
+	assert.Empty(t, spans)
+}
+
+func TestIndexValuesLargeInput(t *testing.T) {
+	var buf bytes.Buffer
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&buf, "{\"id\": %d, \"name\": \"%s\"}\n", i, strings.Repeat("n", i%50))
+	}
+	data := buf.Bytes()
+	spans, err := IndexValues(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, spans, 10000)
+	last := spans[9999]
+	assert.Equal(t, fmt.Sprintf(`{"id": 9999, "name": "%s"}`, strings.Repeat("n", 49)), string(data[last.Start:last.End]))
+}
+
+func TestIndexValuesLargeObject(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&buf, "\"k%d\": [%d, {\"x\": null}],", i, i)
+	}
+	buf.WriteString("\"last\": true}\n[]")
+	data := buf.Bytes()
+	spans, err := IndexValues(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, []ValueSpan{{0, int64(len(data)) - 3}, {int64(len(data)) - 2, int64(len(data))}}, spans)
+}
+
+func TestIndexValuesError(t *testing.T) {
+	data := "1\n[2]\n{\"a\" 3}\n4\n"
+	spans, err := IndexValues(strings.NewReader(data), int64(len(data)))
+	assert.Equal(t, []ValueSpan{{0, 1}, {2, 5}}, spans)
+	var syntaxErr *SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+
+	data = "[1]\n{\"a\":"
+	spans, err = IndexValues(strings.NewReader(data), int64(len(data)))
+	assert.Equal(t, []ValueSpan{{0, 3}}, spans)
+	assert.Error(t, err)
+}
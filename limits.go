@@ -0,0 +1,43 @@
+package sjson
+
+// Limits bundles the bounds a Parser enforces on the values it reads, each
+// field behaving as the Parser field of the same name. Zero fields mean
+// unlimited.
+type Limits struct {
+	MaxDepth        int
+	MaxStringLen    int
+	MaxKeyLen       int
+	MaxNumberLen    int
+	MaxValueBytes   int
+	MaxTotalNodes   int
+	MaxDistinctKeys int
+}
+
+// FeedWithLimits is like Feed, but parses the top-level value b belongs to
+// with limits instead of the bounds set by the parser's fields, so a trusted
+// document can be read with relaxed limits by an otherwise strict parser,
+// without changing its configuration. The override applies until the
+// current value completes, or is discarded, even if the following bytes are
+// passed to Feed; when b precedes a value, it applies to that value. Calling
+// it again before then replaces the override. Limits set with SetMaxDepthAt
+// still apply.
+func (p *Parser) FeedWithLimits(b byte, limits Limits) ([]byte, error) {
+	p.valueLimits, p.limitsSet = limits, true
+	return p.Feed(b)
+}
+
+// limits returns the limits in effect for the value being parsed.
+func (p *Parser) limits() Limits {
+	if p.limitsSet {
+		return p.valueLimits
+	}
+	return Limits{
+		MaxDepth:        p.MaxDepth,
+		MaxStringLen:    p.MaxStringLen,
+		MaxKeyLen:       p.MaxKeyLen,
+		MaxNumberLen:    p.MaxNumberLen,
+		MaxValueBytes:   p.MaxValueBytes,
+		MaxTotalNodes:   p.MaxTotalNodes,
+		MaxDistinctKeys: p.MaxDistinctKeys,
+	}
+}
@@ -0,0 +1,68 @@
+package sjson
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// feedWithLimits feeds data, passing its first byte to FeedWithLimits.
+func feedWithLimits(p *Parser, data string, limits Limits) ([]byte, error) {
+	v, err := p.FeedWithLimits(data[0], limits)
+	if err != nil || len(data) == 1 {
+		return v, err
+	}
+	w, err := feedAll(p, data[1:])
+	if w != nil {
+		v = w
+	}
+	return v, err
+}
+
+func TestFeedWithLimits(t *testing.T) {
+	p := &Parser{MaxDepth: 2, MaxStringLen: 4, MaxNumberLen: 3, MaxTotalNodes: 5}
+	trusted := `{"key": [[["long string"]], 123456, 1, 2, 3]}`
+
+	_, err := feedAll(p, trusted)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+
+	p.Reset()
+	v, err := feedWithLimits(p, trusted, Limits{})
+	require.NoError(t, err)
+	assert.Equal(t, `{"key":[[["long string"]],123456,1,2,3]}`, string(v))
+
+	// The override ends with the value.
+	_, err = feedAll(p, ` "long string"`)
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "MaxStringLen", limitErr.Limit)
+	assert.Equal(t, 4, limitErr.Max)
+
+	// Overrides may tighten limits too, and apply to the value following
+	// whitespace.
+	p.Reset()
+	_, err = feedWithLimits(p, ` [1, 2]`, Limits{MaxTotalNodes: 2})
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "MaxTotalNodes", limitErr.Limit)
+	assert.Equal(t, 2, limitErr.Max)
+
+	p.Reset()
+	_, err = feedWithLimits(p, `{"abc": 1}`, Limits{MaxKeyLen: 2, MaxStringLen: 10})
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "MaxKeyLen", limitErr.Limit)
+
+	p.Reset()
+	_, err = feedWithLimits(p, `[`+strings.Repeat("1,", 10)+`1]`, Limits{MaxValueBytes: 8})
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "MaxValueBytes", limitErr.Limit)
+
+	// A discarded value ends the override.
+	p.Reset()
+	_, err = feedWithLimits(p, `["abcdef`, Limits{})
+	require.NoError(t, err)
+	p.SkipToNextValue(nil)
+	_, err = feedAll(p, `"abcdef"`)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
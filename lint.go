@@ -0,0 +1,61 @@
+package sjson
+
+// IndentWarning reports a line whose indentation is inconsistent.
+type IndentWarning struct {
+	// Offset is the position of the first byte following the indentation.
+	Offset uint64
+	Msg    string
+}
+
+const (
+	indentSpace = 1 << iota
+	indentTab
+)
+
+// SetIndentWarningCallback registers fn to be called for each line within a
+// top-level value whose indentation is inconsistent, as a linter would report
+// it. A line's indentation is the run of spaces and tabs following a newline,
+// up to the next token. It is inconsistent when it holds both spaces and
+// tabs, or when it is made of spaces while the first indented line of the
+// value used tabs, or the other way around. Lines beginning top-level values
+// are not checked. Warnings never affect parsing.
+func (p *Parser) SetIndentWarningCallback(fn func(w IndentWarning)) {
+	p.indentFn = fn
+}
+
+// lintIndent tracks the indentation of lines, ws telling whether b is
+// whitespace outside of a string.
+func (p *Parser) lintIndent(b byte, ws bool) {
+	if ws {
+		switch {
+		case b == '\n':
+			p.inIndent, p.lineIndent = true, 0
+		case !p.inIndent:
+		case b == ' ':
+			p.lineIndent |= indentSpace
+		case b == '\t':
+			p.lineIndent |= indentTab
+		}
+		return
+	}
+	if !p.inIndent {
+		return
+	}
+	p.inIndent = false
+	if len(p.stack) == 0 {
+		return
+	}
+	switch {
+	case p.lineIndent == indentSpace|indentTab:
+		p.indentFn(IndentWarning{Offset: p.offset - 1, Msg: "indentation mixes tabs and spaces"})
+	case p.lineIndent == 0:
+	case p.valueIndent == 0:
+		p.valueIndent = p.lineIndent
+	case p.lineIndent != p.valueIndent:
+		msg := "indentation uses spaces, while previous lines use tabs"
+		if p.lineIndent == indentTab {
+			msg = "indentation uses tabs, while previous lines use spaces"
+		}
+		p.indentFn(IndentWarning{Offset: p.offset - 1, Msg: msg})
+	}
+}
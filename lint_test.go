@@ -0,0 +1,37 @@
+package sjson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndentWarnings(t *testing.T) {
+	var warnings []IndentWarning
+	p := &Parser{AllowUnescapedNewlinesInStrings: true}
+	p.SetIndentWarningCallback(func(w IndentWarning) { warnings = append(warnings, w) })
+
+	_, err := feedAll(p, "{\n  \"a\": [\n    1,\n    \"x\n   y\"\n  ]\n}\n")
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	p.Reset()
+	in := "[\n\t1,\n\t \t2,\n  3,\n\t4\n]\n\t[\n  5\n]"
+	_, err = feedAll(p, in)
+	require.NoError(t, err)
+	assert.Equal(t, []IndentWarning{
+		{Offset: 9, Msg: "indentation mixes tabs and spaces"},
+		{Offset: 14, Msg: "indentation uses spaces, while previous lines use tabs"},
+	}, warnings)
+	assert.Equal(t, byte('2'), in[9])
+	assert.Equal(t, byte('3'), in[14])
+
+	warnings = nil
+	p.Reset()
+	_, err = feedAll(p, "{\n  \"a\": {\n\t\"b\": 1}}")
+	require.NoError(t, err)
+	assert.Equal(t, []IndentWarning{
+		{Offset: 12, Msg: "indentation uses tabs, while previous lines use spaces"},
+	}, warnings)
+}
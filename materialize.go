@@ -0,0 +1,220 @@
+package sjson
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Node is a JSON value held in memory, as built by MaterializeAt.
+type Node struct {
+	Type ValueType
+	// Raw holds the bytes of scalar values as found in the input, including
+	// quotes for strings. It is nil for arrays and objects.
+	Raw []byte
+	// Elements holds the elements of an array.
+	Elements []*Node
+	// Members holds the members of an object, in the order they appear in
+	// the input.
+	Members []Member
+}
+
+// Member is a member of an object Node.
+type Member struct {
+	// Key is the member's key, unescaped.
+	Key   string
+	Value *Node
+}
+
+func (n *Node) expect(t ValueType) error {
+	if n.Type != t {
+		return fmt.Errorf("node holds %s, not %s", n.Type, t)
+	}
+	return nil
+}
+
+// Bool returns the value of a boolean node.
+func (n *Node) Bool() (bool, error) {
+	if err := n.expect(Bool); err != nil {
+		return false, err
+	}
+	return n.Raw[0] == 't', nil
+}
+
+// Float returns the value of a number node.
+func (n *Node) Float() (float64, error) {
+	if err := n.expect(Number); err != nil {
+		return 0, err
+	}
+	return ParseNumber(n.Raw)
+}
+
+// Text returns the value of a string node, unescaped.
+func (n *Node) Text() (string, error) {
+	if err := n.expect(String); err != nil {
+		return "", err
+	}
+	return UnescapeString(n.Raw)
+}
+
+// IsNull returns whether n is a null node.
+func (n *Node) IsNull() bool {
+	return n.Type == Null
+}
+
+// Len returns the number of elements of an array node or of members of an
+// object node, and zero for other nodes.
+func (n *Node) Len() int {
+	return len(n.Elements) + len(n.Members)
+}
+
+// Get returns the value of the member of an object node with the given key,
+// or nil if there's none. When the key appears more than once, the last
+// value wins. Get may be called on a nil Node, so lookups can be chained.
+func (n *Node) Get(key string) *Node {
+	if n == nil {
+		return nil
+	}
+	for i := len(n.Members) - 1; i >= 0; i-- {
+		if n.Members[i].Key == key {
+			return n.Members[i].Value
+		}
+	}
+	return nil
+}
+
+// Index returns the element of an array node at index i, or nil if there's
+// none. Like Get, it may be called on a nil Node.
+func (n *Node) Index(i int) *Node {
+	if n == nil || i < 0 || i >= len(n.Elements) {
+		return nil
+	}
+	return n.Elements[i]
+}
+
+// materializeFrame is a container enclosing the value being read, along with
+// the location of its current element or member.
+type materializeFrame struct {
+	segment PathSegment
+	// keyed indicates whether segment is known: always for arrays, and once
+	// a key was read for objects.
+	keyed bool
+}
+
+// materializer builds the value at a pointer from the tokens of a parser.
+type materializer struct {
+	target []string
+	frames []materializeFrame
+	// nodes holds the containers being built, from the outermost inwards.
+	nodes []*Node
+	key   string
+	root  *Node
+}
+
+// atPointer returns whether the next value read lies at the target pointer.
+func (m *materializer) atPointer() bool {
+	if len(m.frames) != len(m.target) {
+		return false
+	}
+	for i, f := range m.frames {
+		if !f.keyed || f.segment.String() != m.target[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *materializer) token(t Token) {
+	switch t.Kind {
+	case TokenBeginObject, TokenBeginArray:
+		m.begin(&Node{Type: t.Type})
+		if t.Kind == TokenBeginArray {
+			m.frames = append(m.frames, materializeFrame{segment: PathSegment{Index: 0}, keyed: true})
+		} else {
+			m.frames = append(m.frames, materializeFrame{segment: PathSegment{Index: -1}})
+		}
+	case TokenKey:
+		key, err := UnescapeString(t.Value)
+		if err != nil {
+			key = string(t.Value[1 : len(t.Value)-1])
+		}
+		m.frames[len(m.frames)-1] = materializeFrame{segment: PathSegment{Key: key, Index: -1}, keyed: true}
+		m.key = key
+	case TokenValue:
+		m.begin(&Node{Type: t.Type, Raw: append([]byte(nil), t.Value...)})
+		m.completed()
+	case TokenEndObject, TokenEndArray:
+		m.frames = m.frames[:len(m.frames)-1]
+		if len(m.nodes) > 0 {
+			m.nodes = m.nodes[:len(m.nodes)-1]
+		}
+		m.completed()
+	}
+}
+
+// begin records n, a value that just began, if it lies within the target.
+func (m *materializer) begin(n *Node) {
+	if len(m.nodes) > 0 {
+		parent := m.nodes[len(m.nodes)-1]
+		if parent.Type == Array {
+			parent.Elements = append(parent.Elements, n)
+		} else {
+			parent.Members = append(parent.Members, Member{Key: m.key, Value: n})
+		}
+	} else if m.atPointer() {
+		m.root = n
+	} else {
+		return
+	}
+	if n.Type == Array || n.Type == Object {
+		m.nodes = append(m.nodes, n)
+	}
+}
+
+// completed moves past a value that was just completed.
+func (m *materializer) completed() {
+	if len(m.frames) > 0 && m.frames[len(m.frames)-1].segment.IsIndex() {
+		m.frames[len(m.frames)-1].segment.Index++
+	}
+}
+
+// MaterializeAt reads the single JSON value in r and builds the value at
+// pointer, a JSON Pointer, into a tree of Nodes. Only that value is buffered:
+// the rest of the document is validated as it streams past, keeping just
+// the bytes the parser needs, so a single branch of a very large document can
+// be worked with in memory. A nil Node is returned if no value lies at
+// pointer. When an object holds a key more than once, the last value wins.
+func MaterializeAt(r io.Reader, pointer string) (*Node, error) {
+	m, p, err := newMaterializer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	s := singleValue{p: p}
+	if _, err := s.readFrom(r); err != nil {
+		return nil, err
+	}
+	return m.root, nil
+}
+
+// newMaterializer returns a materializer for the value at pointer, along with
+// the parser feeding it, which only buffers that value.
+func newMaterializer(pointer string) (*materializer, *Parser, error) {
+	m := &materializer{}
+	if pointer != "" {
+		if pointer[0] != '/' {
+			return nil, nil, fmt.Errorf("invalid JSON Pointer %q: must start with /", pointer)
+		}
+		m.target = strings.Split(pointer[1:], "/")
+		for i, seg := range m.target {
+			m.target[i] = pointerUnescaper.Replace(seg)
+		}
+	}
+
+	p := &Parser{keepKeys: true}
+	p.discard = !m.atPointer()
+	p.tokenFn = func(t Token) {
+		m.token(t)
+		p.discard = len(m.nodes) == 0 && !m.atPointer()
+	}
+	return m, p, nil
+}
@@ -0,0 +1,130 @@
+package sjson
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaterializeAt(t *testing.T) {
+	doc := `{"skip": [1, {"a": "b"}], "items": [{"id": 1, "tags": ["x", "y"], "ok": true}, {"id": 2.5, "name": "aé", "gone": null}], "a/b": {"c": 3}, "dup": 1, "dup": 2}`
+	at := func(pointer string) *Node {
+		n, err := MaterializeAt(iotest.HalfReader(strings.NewReader(doc)), pointer)
+		require.NoError(t, err, pointer)
+		return n
+	}
+
+	items := at("/items")
+	require.NotNil(t, items)
+	assert.Equal(t, Array, items.Type)
+	assert.Equal(t, 2, items.Len())
+
+	first := items.Index(0)
+	assert.Equal(t, Object, first.Type)
+	assert.Equal(t, []string{"id", "tags", "ok"}, []string{first.Members[0].Key, first.Members[1].Key, first.Members[2].Key})
+	id, err := first.Get("id").Float()
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, id)
+	tag, err := first.Get("tags").Index(1).Text()
+	require.NoError(t, err)
+	assert.Equal(t, "y", tag)
+	ok, err := first.Get("ok").Bool()
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	name, err := at("/items/1/name").Text()
+	require.NoError(t, err)
+	assert.Equal(t, "aé", name)
+	assert.Equal(t, `"aé"`, string(at("/items/1/name").Raw))
+	assert.True(t, at("/items/1/gone").IsNull())
+
+	c, err := at("/a~1b/c").Float()
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, c)
+
+	dup, err := at("/dup").Float()
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, dup)
+
+	root := at("")
+	assert.Equal(t, 5, root.Len())
+	assert.Equal(t, "b", string(root.Get("skip").Index(1).Get("a").Raw[1:2]))
+
+	assert.Nil(t, at("/missing"))
+	assert.Nil(t, at("/items/2"))
+	assert.Nil(t, at("/items/01"))
+	assert.Nil(t, at("/items/0/id/x"))
+	assert.Nil(t, root.Get("missing").Index(0).Get("x"))
+}
+
+func TestMaterializeAtScalars(t *testing.T) {
+	n, err := MaterializeAt(strings.NewReader(` -12.5e1 `), "")
+	require.NoError(t, err)
+	f, err := n.Float()
+	require.NoError(t, err)
+	assert.Equal(t, -125.0, f)
+
+	_, err = n.Text()
+	assert.EqualError(t, err, "node holds number, not string")
+	_, err = n.Bool()
+	assert.EqualError(t, err, "node holds number, not bool")
+	assert.Zero(t, n.Len())
+}
+
+func TestMaterializeAtErrors(t *testing.T) {
+	_, err := MaterializeAt(strings.NewReader(`{}`), "a")
+	assert.EqualError(t, err, `invalid JSON Pointer "a": must start with /`)
+
+	_, err = MaterializeAt(strings.NewReader(`{"a": [1,]}`), "/b")
+	var syntaxErr *SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+
+	_, err = MaterializeAt(strings.NewReader(`{"a": 1} 2`), "/a")
+	assert.Error(t, err)
+}
+
+func TestMaterializeAtBuffersOnlySubtree(t *testing.T) {
+	big := strings.Repeat("x", 1<<16)
+	doc := `{"before": ["` + big + `", {"k": "` + big + `"}], "want": {"v": [1, 2]}, "after": "` + big + `"}`
+	m, p, err := newMaterializer("/want")
+	require.NoError(t, err)
+	maxData := 0
+	for i := 0; i < len(doc); i++ {
+		_, err := p.Feed(doc[i])
+		require.NoError(t, err)
+		if len(p.data) > maxData {
+			maxData = len(p.data)
+		}
+	}
+	assert.Less(t, maxData, 64)
+	require.NotNil(t, m.root)
+	assert.Equal(t, `2`, string(m.root.Get("v").Index(1).Raw))
+}
+
+func TestMaterializeAtLargeObject(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&sb, `"k%d": [%d, {"x": true}],`, i, i)
+	}
+	sb.WriteString(`"want": [1, 2]}`)
+	doc := sb.String()
+
+	m, p, err := newMaterializer("/want")
+	require.NoError(t, err)
+	maxData := 0
+	for i := 0; i < len(doc); i++ {
+		_, err := p.Feed(doc[i])
+		require.NoError(t, err)
+		if len(p.data) > maxData {
+			maxData = len(p.data)
+		}
+	}
+	assert.Less(t, maxData, 64)
+	require.NotNil(t, m.root)
+	assert.Equal(t, 2, m.root.Len())
+}
@@ -0,0 +1,39 @@
+package sjson
+
+// SetMemberCallback registers fn to be called with each object member, at
+// any depth, as soon as its value is complete. fn receives the member's key,
+// quoted and as found in the input, and its value, as it would be returned.
+// Members of nested objects are reported before the member holding them.
+// The slices passed to fn alias the parser's buffer and must be copied if
+// retained. An error returned by fn aborts parsing and is returned by Feed.
+func (p *Parser) SetMemberCallback(fn func(key, value []byte) error) {
+	p.memberFn = fn
+}
+
+// SetRawMemberCallback is like SetMemberCallback, but fn receives the whole
+// member in a single slice, as in `"key":value`, ready to be forwarded. The
+// key and value are kept verbatim, while whitespace is handled as in values
+// returned by the parser: whitespace around the colon is dropped, or
+// collapsed into a single byte with NormalizeWhitespace. The comma
+// separating the member from the next one is not included.
+func (p *Parser) SetRawMemberCallback(fn func(member []byte) error) {
+	p.rawMemberFn = fn
+}
+
+// memberCompleted reports the member whose value, parsed by s, was just
+// completed, if s was an object member.
+func (p *Parser) memberCompleted(s state) error {
+	if len(p.stack) < 2 || p.state().name != pObjectValue {
+		return nil
+	}
+	obj := p.stack[len(p.stack)-2]
+	if p.memberFn != nil {
+		if err := p.memberFn(p.data[obj.keyStart:obj.keyEnd], p.data[s.position:]); err != nil {
+			return err
+		}
+	}
+	if p.rawMemberFn != nil {
+		return p.rawMemberFn(p.data[obj.keyStart:])
+	}
+	return nil
+}
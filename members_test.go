@@ -0,0 +1,62 @@
+package sjson
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemberCallback(t *testing.T) {
+	var members []string
+	p := &Parser{}
+	p.SetMemberCallback(func(key, value []byte) error {
+		members = append(members, string(key)+"="+string(value))
+		return nil
+	})
+	v, err := feedAll(p, `{"a": 1, "b" : {"c\"d": [true, null]}, "e": "x"} [{"f": 2}]`)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"f":2}]`, string(v))
+	assert.Equal(t, []string{
+		`"a"=1`,
+		`"c\"d"=[true,null]`,
+		`"b"={"c\"d":[true,null]}`,
+		`"e"="x"`,
+		`"f"=2`,
+	}, members)
+}
+
+func TestRawMemberCallback(t *testing.T) {
+	var members []string
+	collect := func(member []byte) error {
+		members = append(members, string(member))
+		return nil
+	}
+
+	p := &Parser{}
+	p.SetRawMemberCallback(collect)
+	_, err := feedAll(p, "{\"a\" : 1.5 ,\n \"b\":{ \"c\" :\"x\" } }")
+	require.NoError(t, err)
+	assert.Equal(t, []string{`"a":1.5`, `"c":"x"`, `"b":{"c":"x"}`}, members)
+
+	members = nil
+	p = &Parser{NormalizeWhitespace: true}
+	p.SetRawMemberCallback(collect)
+	_, err = feedAll(p, "{\"a\" :\t1,\n\"b\":[ 2 ]}")
+	require.NoError(t, err)
+	assert.Equal(t, []string{`"a" : 1`, `"b":[ 2 ]`}, members)
+}
+
+func TestMemberCallbackError(t *testing.T) {
+	boom := errors.New("boom")
+	p := &Parser{}
+	p.SetRawMemberCallback(func(member []byte) error {
+		if string(member) == `"b":2` {
+			return boom
+		}
+		return nil
+	})
+	_, err := feedAll(p, `{"a":1,"b":2,"c":3}`)
+	assert.ErrorIs(t, err, boom)
+}
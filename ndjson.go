@@ -0,0 +1,44 @@
+package sjson
+
+import (
+	"fmt"
+	"io"
+)
+
+// ValidateNDJSON checks that r holds newline-delimited JSON: exactly one
+// complete value on each line, with no other content. Blank lines are
+// ignored. It returns how many values were read, and on failure an error
+// wrapping the cause and holding the 1-based number of the offending line.
+func ValidateNDJSON(r io.Reader) (values int, err error) {
+	p := &Parser{NDJSON: true, discard: true}
+	line := 1
+	buf := make([]byte, 4096)
+	for {
+		n, rErr := r.Read(buf)
+		for _, b := range buf[:n] {
+			v, err := p.Feed(b)
+			if err != nil {
+				return values, fmt.Errorf("line %d: %w", line, err)
+			}
+			if v != nil {
+				values++
+			}
+			if b == '\n' {
+				line++
+			}
+		}
+		if rErr == io.EOF {
+			break
+		}
+		if rErr != nil {
+			return values, rErr
+		}
+	}
+	if len(p.stack) > 0 {
+		if _, err := p.finish(); err != nil {
+			return values, fmt.Errorf("line %d: %w", line, err)
+		}
+		values++
+	}
+	return values, nil
+}
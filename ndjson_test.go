@@ -0,0 +1,41 @@
+package sjson
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateNDJSON(t *testing.T) {
+	values, err := ValidateNDJSON(iotest.OneByteReader(strings.NewReader("{\"a\": 1}\n\n[1, \"x\\ny\"]\r\n  \n12\n\"s\"")))
+	require.NoError(t, err)
+	assert.Equal(t, 4, values)
+
+	values, err = ValidateNDJSON(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Zero(t, values)
+
+	cases := map[string]string{
+		"{}\n{} {}\n":   "line 2: ",
+		"1\n[1,\n2]\n":  "line 2: ",
+		"1\n2\n3 x\n":   "line 3: ",
+		"{}\n\n{\"a\":": "line 3: ",
+		"\"a\nb\"\n":    "line 1: ",
+		"{}\n[]\n1.":    "line 3: ",
+	}
+	for in, prefix := range cases {
+		_, err := ValidateNDJSON(strings.NewReader(in))
+		require.Error(t, err, in)
+		assert.True(t, strings.HasPrefix(err.Error(), prefix), "%q: %s", in, err)
+		var syntaxErr *SyntaxError
+		assert.ErrorAs(t, err, &syntaxErr, in)
+	}
+
+	boom := errors.New("boom")
+	_, err = ValidateNDJSON(iotest.ErrReader(boom))
+	assert.ErrorIs(t, err, boom)
+}
@@ -0,0 +1,207 @@
+package sjson
+
+import (
+	"io"
+	"sync"
+)
+
+// singleValue drives a Parser that must read exactly one value, optionally
+// surrounded by whitespace.
+type singleValue struct {
+	p     *Parser
+	value []byte
+}
+
+func (s *singleValue) feed(data []byte) error {
+	p := s.p
+	for _, b := range data {
+		if s.value != nil {
+			p.offset++
+			if !isWsp(b) {
+				return p.fail("unexpected `%c' after value", b)
+			}
+			continue
+		}
+		v, err := p.Feed(b)
+		if err != nil {
+			return err
+		}
+		s.value = v
+	}
+	return nil
+}
+
+func (s *singleValue) finish() ([]byte, error) {
+	if s.value != nil {
+		return s.value, nil
+	}
+	return s.p.finish()
+}
+
+func parseSingle(p *Parser, data []byte) ([]byte, error) {
+	s := singleValue{p: p}
+	if err := s.feed(data); err != nil {
+		return nil, err
+	}
+	return s.finish()
+}
+
+// Parse returns the single JSON value contained in data. Whitespace around
+// the value is allowed, but empty or truncated inputs and any other trailing
+// data result in an error.
+func Parse(data []byte) ([]byte, error) {
+	return parseSingle(&Parser{}, data)
+}
+
+// Normalized is a value read by Normalize.
+type Normalized struct {
+	// Minified holds the value with all insignificant whitespace removed.
+	Minified []byte
+	// Start and End are the offsets, within the source, of the value's
+	// first byte and of the byte following its last one.
+	Start, End uint64
+}
+
+// Normalize is like Parse, but also returns where the value lies within
+// data, so the minified form can be stored while still referring to the
+// original document.
+func Normalize(data []byte) (Normalized, error) {
+	p := &Parser{}
+	v, err := parseSingle(p, data)
+	if err != nil {
+		return Normalized{}, err
+	}
+	start, end := p.LastValueSpan()
+	return Normalized{Minified: v, Start: start, End: end}, nil
+}
+
+// FirstKey reads the first member of the top-level object in data, returning
+// its key, unescaped, and its value. Parsing stops as soon as the value is
+// complete, so the rest of data is never examined, making it a cheap way to
+// read a discriminator field leading tagged messages. An error is returned if
+// data doesn't begin with an object holding at least one member.
+func FirstKey(data []byte) (key, value []byte, err error) {
+	p := &Parser{RequireContainerRoot: true}
+	var rawKey []byte
+	p.completedFn = func(v []byte) {
+		if value == nil && len(p.stack) == 2 {
+			rawKey, value = p.CurrentKey(), v
+		}
+	}
+	for _, b := range data {
+		if _, err := p.Feed(b); err != nil {
+			return nil, nil, err
+		}
+		if value != nil {
+			k, err := UnescapeString(rawKey)
+			if err != nil {
+				return nil, nil, err
+			}
+			return []byte(k), value, nil
+		}
+		if len(p.stack) > 0 && p.stack[0].name == pArray {
+			return nil, nil, p.fail("expected an object, found an array")
+		}
+		if len(p.stack) == 0 && p.valueSeen {
+			return nil, nil, p.fail("expected an object with at least one member")
+		}
+	}
+	return nil, nil, p.syntaxError("unexpected end of input", p.offset)
+}
+
+// ParseInto is like Parse, but uses dst as the working buffer, so a single
+// buffer can be reused across many calls instead of allocating a new one for
+// each document. Any contents of dst are discarded. When dst is large enough,
+// the returned value aliases it, and is only valid until dst is reused.
+func ParseInto(dst []byte, data []byte) (value []byte, err error) {
+	p := intoParsers.Get().(*Parser)
+	p.Reset()
+	p.data = dst[:0]
+	value, err = parseSingle(p, data)
+	p.data = nil
+	intoParsers.Put(p)
+	return value, err
+}
+
+// intoParsers holds the parsers used by ParseInto, so their state stacks are
+// reused along with the callers' buffers.
+var intoParsers = sync.Pool{New: func() any { return &Parser{} }}
+
+// NextValueLength returns how many bytes at the start of data hold its first
+// JSON value, including any whitespace preceding it, so data[:n] can be handed
+// to Parse later. Bytes following the value are not examined, except for the
+// one terminating a top-level number. An error is returned if data doesn't
+// begin with a complete value.
+func NextValueLength(data []byte) (int, error) {
+	p := &Parser{discard: true}
+	for i, b := range data {
+		if len(p.stack) == 1 && p.state().name == pNumber && isDigit(p.prevByte()) && !isNumberByte(b) {
+			// A top-level number ends at the first byte that can't
+			// continue it, whatever that byte is.
+			if _, err := p.finish(); err != nil {
+				return 0, err
+			}
+			return i, nil
+		}
+		v, err := p.Feed(b)
+		if err != nil {
+			return 0, err
+		}
+		if v != nil {
+			return i + 1, nil
+		}
+	}
+	if _, err := p.finish(); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// MinifiedLength returns how many bytes the single JSON value contained in data
+// would occupy once minified, as returned by Parse, without building it. An
+// error is returned if data doesn't hold exactly one valid value.
+func MinifiedLength(data []byte) (int, error) {
+	s := singleValue{p: &Parser{discard: true}}
+	n := 0
+	for i := range data {
+		done := s.value != nil
+		if err := s.feed(data[i : i+1]); err != nil {
+			return 0, err
+		}
+		if !done && s.p.curBuffered {
+			n++
+		}
+	}
+	if _, err := s.finish(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func isNumberByte(b byte) bool {
+	return isDigit(b) || b == '.' || b == 'e' || b == 'E' || b == '+' || b == '-'
+}
+
+// ParseReader is like Parse, but reads its input from r. Data is consumed in
+// chunks, so only the value itself is kept in memory.
+func ParseReader(r io.Reader) ([]byte, error) {
+	s := singleValue{p: &Parser{}}
+	return s.readFrom(r)
+}
+
+// readFrom feeds everything in r, returning the value once r is exhausted.
+func (s *singleValue) readFrom(r io.Reader) ([]byte, error) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if fErr := s.feed(buf[:n]); fErr != nil {
+			return nil, fErr
+		}
+		if err == io.EOF {
+			return s.finish()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package sjson
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]string{
+		` {"a": [1, 2]} `: `{"a":[1,2]}`,
+		"42":              "42",
+		"\n-1.5e3\n":      "-1.5e3",
+		`"str"`:           `"str"`,
+	}
+	for in, out := range tests {
+		t.Run(in, func(t *testing.T) {
+			v, err := Parse([]byte(in))
+			require.NoError(t, err)
+			assert.Equal(t, out, string(v))
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, v := range []string{"", "  ", "[1,2", `{"a":`, "1.", "{} {}", "[] x", "1 2"} {
+		t.Run(v, func(t *testing.T) {
+			_, err := Parse([]byte(v))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	data := `{"items": [` + strings.Repeat(`"value", `, 2000) + `null]}`
+	v, err := ParseReader(iotest.HalfReader(strings.NewReader("\n" + data + "\n")))
+	require.NoError(t, err)
+	assert.Equal(t, strings.ReplaceAll(data, " ", ""), string(v))
+
+	_, err = ParseReader(strings.NewReader(data + data))
+	assert.Error(t, err)
+
+	_, err = ParseReader(strings.NewReader(data[:len(data)-1]))
+	assert.Error(t, err)
+
+	boom := errors.New("boom")
+	_, err = ParseReader(iotest.ErrReader(boom))
+	assert.ErrorIs(t, err, boom)
+
+	v, err = ParseReader(iotest.OneByteReader(bytes.NewReader([]byte("123"))))
+	require.NoError(t, err)
+	assert.Equal(t, "123", string(v))
+}
+
+func TestNormalize(t *testing.T) {
+	src := []byte("\n  {\"a\": [1, 2],\n \"b\": \"x y\"}  \n")
+	n, err := Normalize(src)
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":[1,2],"b":"x y"}`, string(n.Minified))
+	assert.Equal(t, `{"a": [1, 2],`+"\n"+` "b": "x y"}`, string(src[n.Start:n.End]))
+
+	n, err = Normalize([]byte(" 12 "))
+	require.NoError(t, err)
+	assert.Equal(t, Normalized{Minified: []byte("12"), Start: 1, End: 3}, n)
+
+	_, err = Normalize([]byte("[1] [2]"))
+	assert.Error(t, err)
+}
+
+func TestMinifiedLength(t *testing.T) {
+	fixtures, err := os.ReadDir("fixtures")
+	require.NoError(t, err)
+	for _, f := range fixtures {
+		if !strings.HasPrefix(f.Name(), "y_") {
+			continue
+		}
+		data, err := os.ReadFile("fixtures/" + f.Name())
+		require.NoError(t, err)
+		v, err := Parse(data)
+		require.NoError(t, err, f.Name())
+		n, err := MinifiedLength(data)
+		require.NoError(t, err, f.Name())
+		assert.Equal(t, len(v), n, f.Name())
+	}
+
+	n, err := MinifiedLength([]byte(" 12 \n"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	n, err = MinifiedLength([]byte("{ \"a b\" : [ 1 , 2 ] }"))
+	require.NoError(t, err)
+	assert.Equal(t, len(`{"a b":[1,2]}`), n)
+
+	for _, in := range []string{"", " ", "[1,", "[1] 2", "{]"} {
+		_, err := MinifiedLength([]byte(in))
+		assert.Error(t, err, in)
+	}
+}
+
+func TestFirstKey(t *testing.T) {
+	tests := map[string][2]string{
+		`{"type": "foo", "data": [1, 2]}`:  {"type", `"foo"`},
+		` {"t\u0079pe":{"a": [1]}, x`:      {"type", `{"a":[1]}`},
+		`{"n": 12 ,`:                       {"n", "12"},
+		`{"n": 12}`:                        {"n", "12"},
+		`{"k": [{"x": 1}, 2], "k2": true}`: {"k", `[{"x":1},2]`},
+	}
+	for in, want := range tests {
+		key, value, err := FirstKey([]byte(in))
+		require.NoError(t, err, in)
+		assert.Equal(t, want[0], string(key), in)
+		assert.Equal(t, want[1], string(value), in)
+	}
+
+	for _, in := range []string{``, `  `, `{}`, `[1]`, `"type"`, `12`, `{"type"`, `{"type": 12`, `{1: 2}`} {
+		_, _, err := FirstKey([]byte(in))
+		assert.Error(t, err, in)
+	}
+}
+
+func TestParseInto(t *testing.T) {
+	dst := make([]byte, 0, 64)
+	v, err := ParseInto(dst, []byte(` {"a": [1, 2]} `))
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":[1,2]}`, string(v))
+	assert.Same(t, &dst[:1][0], &v[0])
+
+	v, err = ParseInto(v, []byte(`"b"`))
+	require.NoError(t, err)
+	assert.Equal(t, `"b"`, string(v))
+
+	v, err = ParseInto(nil, []byte(`[true]`))
+	require.NoError(t, err)
+	assert.Equal(t, `[true]`, string(v))
+
+	_, err = ParseInto(dst, []byte(`[1,`))
+	assert.Error(t, err)
+}
+
+var smallDocument = []byte(`{"id": 12, "name": "item", "tags": ["a", "b"], "ok": true}`)
+
+func BenchmarkParseSmall(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(smallDocument); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseIntoSmall(b *testing.B) {
+	b.ReportAllocs()
+	dst := make([]byte, 0, 128)
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseInto(dst, smallDocument); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestNextValueLength(t *testing.T) {
+	cases := map[string]int{
+		`{"a": [1, 2]} {"b": 3}`: 13,
+		`  [1]`:                  5,
+		`"a,b"rest`:              5,
+		`true,false`:             4,
+		`12`:                     2,
+		`12 `:                    2,
+		"-1.5e3,4":               6,
+		`12]`:                    2,
+		`0x`:                     1,
+	}
+	for in, expected := range cases {
+		n, err := NextValueLength([]byte(in))
+		require.NoError(t, err, in)
+		assert.Equal(t, expected, n, in)
+	}
+
+	for _, in := range []string{``, `   `, `[1,`, `{"a"`, `1.`, `1.x`, `-`, `x`} {
+		_, err := NextValueLength([]byte(in))
+		assert.Error(t, err, in)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		`{"a": [1, 2.5e3, "x\"y", true, false, null]}`,
+		`[1,]`, `{"a":1 "b":2}`, `-Infinity`, `[NaN, 1 2,,3]`, `"😀"`, `{ }`, `01`,
+		`{"a\\":1":2}`, `["\\"x"]`, `"\u12"`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, err := Parse(data)
+		if err == nil {
+			again, err := Parse(v)
+			if err != nil || !bytes.Equal(v, again) {
+				t.Fatalf("reparsing %q gave %q, %v", v, again, err)
+			}
+			_, _ = Unmarshal(data)
+			_, _ = Canonicalize(data)
+		}
+		_, _ = Tokens(data)
+		_, _ = Analyze(data)
+		_, _ = NextValueLength(data)
+
+		p := &Parser{
+			AllowNonFiniteNumbers:            true,
+			AllowWhitespaceSeparatedElements: true,
+			AllowElision:                     true,
+			AllowTopLevelCommas:              true,
+			NormalizeWhitespace:              true,
+			RejectDuplicateKeys:              true,
+			CollectErrors:                    true,
+		}
+		p.ValidateAt("/a", func([]byte) error { return nil })
+		for _, b := range data {
+			_, _ = p.Feed(b)
+			_ = p.Path()
+		}
+		_ = p.DebugDump()
+	})
+}
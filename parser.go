@@ -1,8 +1,12 @@
 package sjson
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"io"
+	"math"
+	"strconv"
+	"unsafe"
 )
 
 type parserState int
@@ -21,6 +25,9 @@ const (
 	pNumber
 	pObjectKey
 	pObjectValue
+	pNaN
+	pInfinity
+	pNegInfinity
 )
 
 func (p parserState) String() string {
@@ -43,11 +50,88 @@ func (p parserState) String() string {
 		return "pObjectKey"
 	case pObjectValue:
 		return "pObjectValue"
+	case pNaN:
+		return "pNaN"
+	case pInfinity:
+		return "pInfinity"
+	case pNegInfinity:
+		return "pNegInfinity"
+	case pNone:
+		return "pNone"
 	default:
-		panic("invalid state")
+		return fmt.Sprintf("parserState(%d)", int(p))
 	}
 }
 
+// ValueType identifies the kind of a JSON value.
+type ValueType int
+
+const (
+	Null ValueType = iota
+	Bool
+	Number
+	String
+	Array
+	Object
+)
+
+func (v ValueType) String() string {
+	switch v {
+	case Null:
+		return "null"
+	case Bool:
+		return "bool"
+	case Number:
+		return "number"
+	case String:
+		return "string"
+	case Array:
+		return "array"
+	case Object:
+		return "object"
+	default:
+		return "invalid"
+	}
+}
+
+// valueTypeOf returns the type of the value beginning with b, and whether b
+// may begin a value at all under the parser's options.
+func (p *Parser) valueTypeOf(b byte) (ValueType, bool) {
+	switch {
+	case b == 't' || b == 'f':
+		return Bool, true
+	case b == 'n':
+		return Null, true
+	case b == quote:
+		return String, true
+	case b == leftCurly:
+		return Object, true
+	case b == leftSquared:
+		return Array, true
+	case b == '-' || (b >= '0' && b <= '9'):
+		return Number, true
+	case b == 'N' || b == 'I':
+		return Number, p.AllowNonFiniteNumbers
+	}
+	return 0, false
+}
+
+// NumberKind distinguishes integer-shaped numbers from numbers containing a
+// fraction or an exponent.
+type NumberKind int
+
+const (
+	Integer NumberKind = iota
+	Float
+)
+
+func (k NumberKind) String() string {
+	if k == Float {
+		return "float"
+	}
+	return "integer"
+}
+
 const (
 	quote        = '"'
 	leftCurly    = '{'
@@ -61,18 +145,566 @@ func isWsp(b byte) bool {
 }
 
 type state struct {
-	name     parserState
-	position uint
+	name parserState
+	// position is the index in data of the first byte of the token parsed
+	// by this state, which is appended before the state is pushed. States
+	// within objects, pObjectKey and pObjectValue, parse no token of their
+	// own, and point at the byte preceding them instead. It always indexes
+	// into data, so it can't exceed the maximum int.
+	position int
+	// offset is the position in the input stream of the byte that pushed
+	// this state.
+	offset uint64
+	// index holds how many elements were started in an array, or how many
+	// keys were read in an object.
+	index int
+	// keyStart and keyEnd delimit, in data, the quoted key of the object
+	// member being parsed. keyEnd is zero when no key was read yet.
+	keyStart, keyEnd int
+	// keys holds the keys already seen in an object, when duplicated keys
+	// are rejected.
+	keys map[string]struct{}
+	// lastKey holds the previous key of an object, when sorted keys are
+	// required.
+	lastKey string
 }
 
 type Parser struct {
-	data  []byte
-	stack []state
+	// AllowedTopLevelTypes restricts which kinds of values may appear at the
+	// top level. When empty, all types are accepted. Nested values are not
+	// affected.
+	AllowedTopLevelTypes []ValueType
+
+	// RequireContainerRoot rejects top-level values that are neither objects
+	// nor arrays. It is a shorthand for setting AllowedTopLevelTypes to
+	// Object and Array.
+	RequireContainerRoot bool
+
+	// MaxConsecutiveWhitespace limits how many whitespace bytes may appear in
+	// a row between tokens. Zero means unlimited.
+	MaxConsecutiveWhitespace int
+
+	// AllowedKeys, when not nil, rejects any object key not present in it
+	// with a true value. Keys are compared after escape sequences are
+	// resolved.
+	AllowedKeys map[string]bool
+
+	// AllowedKeysDepth restricts AllowedKeys to objects at the given nesting
+	// depth, where 1 is an object at the top level, 2 an object directly
+	// within it, and so on. Zero applies AllowedKeys to every object.
+	AllowedKeysDepth int
+
+	// RejectDuplicateKeys rejects objects having the same key more than
+	// once. Keys are compared after escape sequences are resolved.
+	RejectDuplicateKeys bool
+
+	// RequireSortedKeys rejects objects whose keys are not in strictly
+	// ascending order, compared byte-wise after escape sequences are
+	// resolved. As the order is strict, duplicate keys are rejected too.
+	RequireSortedKeys bool
+
+	// CollectErrors makes Feed record syntax errors instead of returning
+	// them, so many problems can be reported in a single pass. After each
+	// error, the value being parsed is discarded and input is skipped until
+	// a byte that may begin a new value is found. This resynchronization is
+	// a heuristic: it may resume in the middle of a damaged value, causing
+	// further spurious errors, or skip over problems entirely. Errors are
+	// available through Errors. Limit errors are still returned by Feed.
+	CollectErrors bool
+
+	// AllowTopLevelCommas accepts a single comma between top-level values,
+	// as in `{},{}`. A comma before the first value or two commas in a row
+	// are rejected, while a trailing comma at the end of the stream goes
+	// unnoticed.
+	AllowTopLevelCommas bool
+
+	// NDJSON makes the parser read newline-delimited JSON, where each
+	// top-level value is a record on a line of its own. Record boundaries
+	// are only recognized outside of values: a raw newline within a record,
+	// including inside a string, is rejected, while the escape sequence \n
+	// is not a newline and is accepted. Records on the same line are
+	// rejected; blank lines are ignored.
+	NDJSON bool
+
+	// LineTerminated makes a newline the terminator of top-level values: a
+	// value is only returned once the newline ending its line is fed, and
+	// anything but spaces, tabs or carriage returns between the value and
+	// that newline is rejected. A number on its own line is thus returned as
+	// soon as the line ends, instead of when the next byte or the end of
+	// input is seen. Unlike NDJSON, values may still span several lines, and
+	// blank lines are skipped. A Decoder reaching the end of its input
+	// returns a value still awaiting its newline.
+	LineTerminated bool
+
+	// NormalizeWhitespace keeps whitespace found between tokens instead of
+	// dropping it, collapsing each run into a single byte: a newline if the
+	// run held one, a space otherwise. Whitespace surrounding top-level
+	// values is still dropped.
+	NormalizeWhitespace bool
+
+	// SkipBOM ignores a UTF-8 byte order mark (EF BB BF) at the start of
+	// the stream.
+	SkipBOM bool
+
+	// SkipBOMPerValue ignores a UTF-8 byte order mark before each top-level
+	// value, as found when files each starting with one are concatenated.
+	// A byte order mark within a value is still rejected.
+	SkipBOMPerValue bool
+
+	// MaxDepth limits how deeply arrays and objects may be nested. Zero
+	// means unlimited.
+	MaxDepth int
+
+	// MaxStringLen limits the length in bytes of strings (including object
+	// keys, unless MaxKeyLen is set), as they appear in the input. Zero
+	// means unlimited.
+	MaxStringLen int
+
+	// MaxKeyLen limits the length in bytes of object keys, as they appear
+	// in the input. When set, keys are bounded by it instead of
+	// MaxStringLen, so keys and string values can be limited separately.
+	// Zero means keys are only subject to MaxStringLen.
+	MaxKeyLen int
+
+	// MaxNumberLen limits the length in bytes of numbers. Zero means
+	// unlimited.
+	MaxNumberLen int
+
+	// MaxValueBytes limits the size of the buffered bytes of a single
+	// top-level value. Zero means unlimited.
+	MaxValueBytes int
+
+	// MaxTotalNodes limits how many values, counting both scalars and
+	// containers at any depth, a single top-level value may hold, including
+	// itself. Zero means unlimited.
+	MaxTotalNodes int
+
+	// MaxDistinctKeys limits how many distinct keys, compared after escape
+	// sequences are resolved, the objects of a single top-level value may
+	// hold altogether. It guards against inputs flooding the consumer with
+	// keys. Up to 256 distinct keys are counted exactly; rather than storing
+	// more keys, larger counts are estimated with HyperLogLog in a fixed
+	// 1 KiB, so they are approximate, typically deviating by 3 to 4%. Inputs
+	// holding slightly fewer keys than such a limit may thus be rejected,
+	// and slightly more accepted; with a limit 10% above the actual count,
+	// fewer than one value in two hundred is falsely rejected. Keys are
+	// hashed with a seed chosen at random by each process, so whether a
+	// value near the limit is accepted can differ from one run to the next.
+	// Use RejectDuplicateKeys for an exact check within each object. Zero
+	// means unlimited.
+	MaxDistinctKeys int
+
+	// AllowNonFiniteNumbers accepts the non-standard NaN, Infinity and
+	// -Infinity tokens as numbers. Only these exact spellings are accepted,
+	// so they are always returned in this canonical form.
+	AllowNonFiniteNumbers bool
+
+	// CaseInsensitiveLiterals accepts true, false and null spelled in any
+	// case, as in `True` or `NULL`, as emitted by some legacy producers.
+	// They are returned in lowercase. As NaN also begins with 'N', null
+	// must begin with a lowercase 'n' when AllowNonFiniteNumbers is set.
+	CaseInsensitiveLiterals bool
+
+	// AllowUnescapedNewlinesInStrings accepts raw line feeds and carriage
+	// returns within strings, which JSON requires to be escaped. Other
+	// control characters are still rejected.
+	AllowUnescapedNewlinesInStrings bool
+
+	// ValidateUTF8 rejects strings (including object keys) holding bytes
+	// that are not valid UTF-8. Only raw bytes are concerned: escape
+	// sequences are checked by ValidateEscapes.
+	ValidateUTF8 bool
+
+	// ValidateEscapes rejects strings (including object keys) holding
+	// invalid escape sequences: an unknown escape character, a \u not
+	// followed by four hexadecimal digits, or a UTF-16 surrogate not paired
+	// with its other half, as in "\uD800". As unpaired surrogates can't be
+	// encoded in UTF-8, they are rejected whether ValidateUTF8 is set or
+	// not: each option only checks its own part of a string.
+	ValidateEscapes bool
+
+	// NormalizeEscapes rewrites strings (including object keys) with a
+	// canonical escaping, as Canonicalize does: only quotes, backslashes
+	// and control characters are escaped, and everything else is kept as
+	// literal UTF-8, so `"\u0041\/"` is returned as `"A/"`. Strings spelled
+	// differently by different producers can then be compared byte-wise.
+	// Escapes resolve as in UnescapeString, so unpaired surrogates become
+	// U+FFFD. Other escapes are checked as with ValidateEscapes, so
+	// malformed and non-standard ones are rejected at their offending byte,
+	// apart from \xHH when AllowHexEscapes is set and those registered with
+	// a decoder.
+	NormalizeEscapes bool
+
+	// AllowHexEscapes makes ValidateEscapes accept the non-standard \xHH
+	// escape, where HH are two hexadecimal digits, as RegisterEscape('x', 2, nil)
+	// does.
+	AllowHexEscapes bool
+
+	// AllowDigitSeparators accepts underscores between the digits of
+	// numbers, as in 1_000_000. An underscore must sit between two digits,
+	// so it can't begin or end a number, appear twice in a row, or be
+	// adjacent to '.' or an exponent. Returned values keep underscores;
+	// ParseNumber strips them.
+	AllowDigitSeparators bool
+
+	// NumbersAsStrings makes returned values hold numbers as strings, so
+	// `[1e10]` is returned as `["1e10"]`, keeping their exact text for
+	// consumers that would otherwise decode them as floats. Numbers are
+	// still validated, and reported as such by LastType and Tokens.
+	NumbersAsStrings bool
+
+	// ForbidExponents rejects numbers written in exponent notation, such as
+	// 1e3, for consumers that can't handle it.
+	ForbidExponents bool
+
+	// AllowWhitespaceSeparatedElements accepts array elements separated by
+	// whitespace alone, as in `[1 2 3]`. Commas may still be used, and both
+	// styles may be mixed within the same array. Returned values always use
+	// commas between elements.
+	AllowWhitespaceSeparatedElements bool
+
+	// AllowElision accepts empty array elements, as in `[1,,2]` or `[,1]`,
+	// and returns them as null, following JavaScript semantics, including to
+	// tokenizers, validators and schemas. A trailing comma does not denote
+	// an elided element, and is still rejected.
+	AllowElision bool
+
+	valueCallback      func(value []byte, start, end uint64) error
+	validators         map[string][]func(value []byte) error
+	keyCallback        func(key []byte) error
+	memberFn           func(key, value []byte) error
+	rawMemberFn        func(member []byte) error
+	numberCallback     func(raw []byte) ([]byte, error)
+	completedFn        func(value []byte)
+	escapes            map[byte]customEscape
+	indentFn           func(w IndentWarning)
+	tee                io.Writer
+	schema             *Schema
+	teeBuf             [1]byte
+	depthLimits        map[string]int
+	tokenFn            func(t Token)
+	whitespaceCallback func(ws []byte, context WhitespaceContext)
+	errFormatter       func(e SyntaxError) string
+	tracer             func(e TraceEvent)
+	// discard makes the parser keep only the bytes it needs to validate
+	// the input, as done by Validator.
+	discard bool
+	// keepKeys exempts object keys from discard, so they reach tokenFn
+	// whole.
+	keepKeys bool
+
+	parseState
 }
 
+// parseState holds everything a Parser tracks while parsing, as opposed to
+// its configuration. Reset relies on every piece of per-parse state living
+// here.
+type parseState struct {
+	data   []byte
+	stack  []state
+	offset uint64
+	wsRun  int
+	depth  int
+	// nodes counts the values started within the current top-level value.
+	nodes int
+	// distinct estimates the distinct keys held by the current top-level
+	// value, with MaxDistinctKeys.
+	distinct *keyEstimator
+	// wsBuf holds the current run of whitespace, when a whitespace
+	// callback is set.
+	wsBuf []byte
+
+	maxDepth     int
+	maxStringLen int
+	// valueMaxDepth is the deepest nesting reached by the current top-level
+	// value, and lastMaxDepth the one of the last value returned.
+	valueMaxDepth int
+	lastMaxDepth  int
+	maxKeyLen     int
+	// afterWsp indicates whether the byte being parsed was preceded by
+	// whitespace outside of a string.
+	afterWsp bool
+	// valueLimits overrides the parser's limits for the current top-level
+	// value when limitsSet is set, as done by FeedWithLimits.
+	valueLimits Limits
+	limitsSet   bool
+	// held is a top-level value awaiting its newline, with LineTerminated.
+	held []byte
+	// bomRead counts the bytes of a byte order mark read so far, and
+	// bomSeen whether one preceded the value about to be parsed.
+	bomRead int
+	bomSeen bool
+	// inIndent indicates whether only whitespace followed the last newline,
+	// lineIndent holding the kinds of indentation found since, and
+	// valueIndent the kind used by the value being parsed. Only tracked
+	// with an indent warning callback.
+	inIndent    bool
+	lineIndent  int
+	valueIndent int
+	// cur is the byte being parsed, and curBuffered whether it was
+	// appended to data.
+	cur         byte
+	curBuffered bool
+	// escaped indicates whether the previous byte of the string being
+	// parsed began an escape sequence.
+	escaped bool
+	// hexLeft counts the hexadecimal digits of a \u escape yet to be read,
+	// escRune accumulates them, and lowPending indicates whether a high
+	// surrogate awaits its low half. Only tracked with ValidateEscapes.
+	hexLeft    int
+	escChar    byte
+	escRune    rune
+	lowPending bool
+	// utf8Left counts the continuation bytes of a UTF-8 sequence yet to be
+	// read, the next of which must lie within [utf8Lo, utf8Hi]. Only
+	// tracked with ValidateUTF8.
+	utf8Left       int
+	utf8Lo, utf8Hi byte
+
+	// seenDot and seenExp indicate whether the number being parsed has a
+	// fractional part or an exponent.
+	seenDot, seenExp bool
+
+	valueType      ValueType
+	numberKind     NumberKind
+	lastType       ValueType
+	lastNumberKind NumberKind
+
+	valueStart uint64
+	lastStart  uint64
+	lastEnd    uint64
+
+	// valueSeen is set once a top-level value completes. sepSeen and
+	// commaSeen record whether a separator, and a comma in particular,
+	// appeared since then; valueSep and lastSep keep sepSeen for the value
+	// being parsed and the last one returned.
+	valueSeen bool
+	sepSeen   bool
+	commaSeen bool
+	valueSep  bool
+	lastSep   bool
+
+	errs     []SyntaxError
+	skipping bool
+	hookErr  error
+	// pendingWsp holds the byte a run of whitespace collapses into while
+	// NormalizeWhitespace is set, until the next token is appended.
+	pendingWsp byte
+	// err latches the first error returned by Feed, so a parser left in a
+	// broken state refuses further input until it is reset.
+	err error
+}
+
+// Reset discards all parsing state, including partially parsed values and
+// counters, so the parser can be reused for a new stream. Configuration
+// fields are kept as they are, making it safe to return parsers to a pool
+// and reuse them with the same options.
 func (p *Parser) Reset() {
+	p.parseState = p.freshState()
+}
+
+// ResetWith resets the parser and replaces its configuration with the one
+// from template. The template's own parsing state is neither copied nor
+// modified. Maps and slices, such as AllowedKeys or the validators set with
+// ValidateAt, are copied, so configuring either parser afterwards doesn't
+// affect the other. Callbacks, the tee writer and the schema are shared with
+// the template, and must be safe for use by every parser reset from it.
+func (p *Parser) ResetWith(template *Parser) {
+	state := p.freshState()
+	*p = *template
+	p.parseState = state
+	p.cloneConfig()
+}
+
+// cloneConfig replaces the maps and slices of the parser's configuration
+// with copies, so they are no longer shared with the parser it was copied
+// from.
+func (p *Parser) cloneConfig() {
+	if p.AllowedTopLevelTypes != nil {
+		p.AllowedTopLevelTypes = append([]ValueType(nil), p.AllowedTopLevelTypes...)
+	}
+	if p.AllowedKeys != nil {
+		keys := make(map[string]bool, len(p.AllowedKeys))
+		for k, v := range p.AllowedKeys {
+			keys[k] = v
+		}
+		p.AllowedKeys = keys
+	}
+	if p.validators != nil {
+		validators := make(map[string][]func(value []byte) error, len(p.validators))
+		for path, fns := range p.validators {
+			validators[path] = append([]func(value []byte) error(nil), fns...)
+		}
+		p.validators = validators
+	}
+	if p.escapes != nil {
+		escapes := make(map[byte]customEscape, len(p.escapes))
+		for c, e := range p.escapes {
+			escapes[c] = e
+		}
+		p.escapes = escapes
+	}
+	if p.depthLimits != nil {
+		limits := make(map[string]int, len(p.depthLimits))
+		for pointer, max := range p.depthLimits {
+			limits[pointer] = max
+		}
+		p.depthLimits = limits
+	}
+}
+
+// freshState returns an empty parseState reusing the parser's buffers.
+func (p *Parser) freshState() parseState {
+	return parseState{data: p.data[:0], stack: p.stack[:0], wsBuf: p.wsBuf[:0]}
+}
+
+// MaxDepthReached returns the deepest nesting of arrays and objects seen since
+// the parser was created or last reset.
+func (p *Parser) MaxDepthReached() int {
+	return p.maxDepth
+}
+
+// MaxStringLenReached returns the length in bytes, as found in the input, of
+// the longest string value seen since the parser was created or last reset.
+// Object keys are not taken into account; see MaxKeyLenReached.
+func (p *Parser) MaxStringLenReached() int {
+	return p.maxStringLen
+}
+
+// MaxKeyLenReached returns the length in bytes, as found in the input, of the
+// longest object key seen since the parser was created or last reset.
+func (p *Parser) MaxKeyLenReached() int {
+	return p.maxKeyLen
+}
+
+// MemoryFootprint returns an estimate of how many bytes the parser retains:
+// the capacity of its buffers and state stack, the keys held to detect
+// duplicates or check their order, including those of frames no longer in
+// use, the estimator of MaxDistinctKeys, and its configuration, such as the
+// validators set with ValidateAt and the schema. Configuration shared with
+// other parsers, as after ResetWith, is counted by each of them. Overhead
+// from the Go runtime, such as map buckets, is not accounted for. Pools may
+// use it to discard parsers that grew too large.
+func (p *Parser) MemoryFootprint() int {
+	n := cap(p.data) + cap(p.wsBuf)
+	n += cap(p.stack) * int(unsafe.Sizeof(state{}))
+	n += cap(p.errs) * int(unsafe.Sizeof(SyntaxError{}))
+	for _, s := range p.stack[:cap(p.stack)] {
+		for k := range s.keys {
+			n += len(k) + int(unsafe.Sizeof(k))
+		}
+		n += len(s.lastKey)
+	}
+	for _, e := range p.errs {
+		n += cap(e.Key)
+	}
+	if p.distinct != nil {
+		n += int(unsafe.Sizeof(*p.distinct)) + len(p.distinct.exact)*8
+	}
+	return n + p.configFootprint()
+}
+
+// configFootprint estimates the bytes held by the parser's configuration,
+// for MemoryFootprint.
+func (p *Parser) configFootprint() int {
+	n := cap(p.AllowedTopLevelTypes) * int(unsafe.Sizeof(ValueType(0)))
+	for k := range p.AllowedKeys {
+		n += len(k) + int(unsafe.Sizeof(k)) + 1
+	}
+	for path, fns := range p.validators {
+		n += len(path) + int(unsafe.Sizeof(path)) + cap(fns)*int(unsafe.Sizeof(fns[0]))
+	}
+	for pointer := range p.depthLimits {
+		n += len(pointer) + int(unsafe.Sizeof(pointer)) + int(unsafe.Sizeof(0))
+	}
+	n += len(p.escapes) * int(unsafe.Sizeof(customEscape{})+1)
+	if p.schema != nil {
+		n += p.schema.footprint()
+	}
+	return n
+}
+
+// FeedOwned is like Feed, but returns a copy of a completed value instead of
+// a slice of the parser's buffer, so it may be retained or sent to other
+// goroutines while the parser keeps reading. Each completed value costs an
+// allocation, which Feed avoids.
+func (p *Parser) FeedOwned(b byte) ([]byte, error) {
+	v, err := p.Feed(b)
+	if v == nil {
+		return nil, err
+	}
+	return append([]byte(nil), v...), err
+}
+
+// SkipToNextValue discards any partially parsed value and scans data for the
+// first byte that may begin a new value, returning how many bytes precede it.
+// When no such byte exists, len(data) is returned. Bytes skipped are still
+// accounted for in Offset, so callers may keep feeding data[consumed:]. Any
+// error latched by Feed is cleared.
+//
+// This is a heuristic: a byte found in the middle of a damaged value (such as
+// a quote or a digit) is indistinguishable from the start of a new one.
+func (p *Parser) SkipToNextValue(data []byte) (consumed int) {
 	p.data = p.data[:0]
 	p.stack = p.stack[:0]
+	p.wsRun = 0
+	p.depth = 0
+	p.pendingWsp = 0
+	p.escaped = false
+	p.held = nil
+	p.limitsSet = false
+	p.err = nil
+	for consumed < len(data) {
+		if p.canBeginValue(data[consumed]) {
+			break
+		}
+		consumed++
+	}
+	p.offset += uint64(consumed)
+	if p.tee != nil && consumed > 0 {
+		p.err = p.teeWrite(data[:consumed])
+	}
+	return consumed
+}
+
+func (p *Parser) canBeginValue(b byte) bool {
+	_, ok := p.valueTypeOf(b)
+	return ok
+}
+
+// LastValueHadSeparator returns whether the last top-level value returned by
+// the parser was preceded by a separator since the value before it: a newline,
+// or a comma when AllowTopLevelCommas is set. Other whitespace does not count.
+// It returns false for the first value of a stream.
+func (p *Parser) LastValueHadSeparator() bool {
+	return p.lastSep
+}
+
+// LastType returns the type of the last top-level value returned by the
+// parser.
+func (p *Parser) LastType() ValueType {
+	return p.lastType
+}
+
+// LastNumberKind returns whether the last top-level value returned by the
+// parser was an integer or a floating-point number. The result is only
+// meaningful when LastType returns Number.
+func (p *Parser) LastNumberKind() NumberKind {
+	return p.lastNumberKind
+}
+
+// LastValueMaxDepth returns the deepest nesting of arrays and objects within
+// the last top-level value returned by the parser, where a scalar has a depth
+// of zero and `[[]]` a depth of two. It is the per-value analog of
+// MaxDepthReached, letting deeply nested values be routed differently.
+func (p *Parser) LastValueMaxDepth() int {
+	return p.lastMaxDepth
+}
+
+// Offset returns the amount of bytes fed to the parser since it was created
+// or last reset.
+func (p *Parser) Offset() uint64 {
+	return p.offset
 }
 
 func (p *Parser) state() state {
@@ -91,7 +723,7 @@ func (p *Parser) prevRelByte() byte {
 	if len(p.data) == 0 {
 		return 0x00
 	}
-	if len(p.data)-1 < int(p.state().position) {
+	if len(p.data)-1 < p.state().position {
 		return 0x00
 	}
 
@@ -102,14 +734,63 @@ func (p *Parser) pushState(s parserState) {
 	if debug {
 		fmt.Printf("pushState %s\n", s)
 	}
+	if p.tracer != nil {
+		from := pNone
+		if len(p.stack) > 0 {
+			from = p.state().name
+		}
+		p.trace(TracePush, from, s)
+	}
+	p.push(s)
+}
+
+func (p *Parser) push(s parserState) {
+	if s == pArray || s == pObject {
+		p.depth++
+		if p.depth > p.maxDepth {
+			p.maxDepth = p.depth
+		}
+		if p.depth > p.valueMaxDepth {
+			p.valueMaxDepth = p.depth
+		}
+	}
+	pos := len(p.data) - 1
+	if pos < 0 {
+		pos = 0
+	}
+	var offset uint64
+	if p.offset > 0 {
+		offset = p.offset - 1
+	}
+	if s == pString {
+		p.hexLeft, p.lowPending, p.utf8Left = 0, false, 0
+	}
 	p.stack = append(p.stack, state{
 		name:     s,
-		position: uint(len(p.data) - 1),
+		position: pos,
+		offset:   offset,
 	})
 }
 
+// token returns the bytes buffered since the current state was pushed.
+func (p *Parser) token() []byte {
+	pos := p.state().position
+	if pos < 0 || pos > len(p.data) {
+		return nil
+	}
+	return p.data[pos:]
+}
+
 func (p *Parser) fail(why string, args ...any) error {
-	return fmt.Errorf("failed parsing stream: %s at position %d", fmt.Sprintf(why, args...), len(p.data)-1)
+	return p.syntaxError(fmt.Sprintf(why, args...), p.offset-1)
+}
+
+func (p *Parser) syntaxError(msg string, offset uint64) *SyntaxError {
+	return &SyntaxError{Msg: msg, Offset: offset, format: p.errFormatter}
+}
+
+func (p *Parser) limit(name string, max int) error {
+	return &LimitError{Limit: name, Max: max, Offset: p.offset - 1}
 }
 
 func (p *Parser) popState() {
@@ -123,15 +804,271 @@ func (p *Parser) popState() {
 		}
 		fmt.Printf("popState (current was %s, will be %s)\n", p.state().name, next)
 	}
+	popped := p.state()
+	if p.tracer != nil {
+		p.trace(TracePop, pNone, popped.name)
+	}
+	if popped.name == pArray || popped.name == pObject {
+		p.depth--
+	}
 	p.stack = p.stack[:len(p.stack)-1]
+
+	switch popped.name {
+	case pObjectKey, pObjectValue:
+		return
+	case pString:
+		if len(p.stack) > 0 && p.state().name == pObjectKey {
+			return
+		}
+	case pNumber:
+		if p.numberCallback != nil {
+			p.rewriteNumber(popped)
+		}
+		if p.NumbersAsStrings {
+			p.quoteNumber(popped)
+		}
+	case pNaN, pInfinity, pNegInfinity:
+		if p.NumbersAsStrings {
+			p.quoteNumber(popped)
+		}
+	}
+	if p.tokenFn != nil {
+		p.emitValueToken(popped)
+	}
+	p.valueCompleted(popped)
 }
 
 func (p *Parser) replaceState(new parserState) {
 	if debug {
 		fmt.Printf("replaceState %s -> %s\n", p.state().name, new)
 	}
-	p.popState()
-	p.pushState(new)
+	if p.tracer != nil {
+		p.trace(TraceReplace, p.state().name, new)
+	}
+	offset := p.state().offset
+	p.stack = p.stack[:len(p.stack)-1]
+	p.push(new)
+	p.stack[len(p.stack)-1].offset = offset
+}
+
+// valueCompleted is called whenever a value, at any depth, is fully parsed.
+// s is the state that parsed it, which is no longer in the stack. Errors
+// found here are stored in hookErr, to be returned once the current byte is
+// processed.
+func (p *Parser) valueCompleted(s state) {
+	if p.hookErr != nil {
+		return
+	}
+	if p.completedFn != nil {
+		p.completedFn(p.data[s.position:])
+	}
+	if p.memberFn != nil || p.rawMemberFn != nil {
+		if err := p.memberCompleted(s); err != nil {
+			p.hookErr = err
+			return
+		}
+	}
+	if p.schema != nil {
+		if err := p.checkSchemaValue(s, p.data[s.position:]); err != nil {
+			p.hookErr = err
+			return
+		}
+	}
+	if len(p.validators) == 0 {
+		return
+	}
+	path := p.Path()
+	for _, fn := range p.validators[path] {
+		if err := fn(p.data[s.position:]); err != nil {
+			p.hookErr = &ValidationError{Path: path, Offset: p.offset - 1, Err: err}
+			return
+		}
+	}
+}
+
+// CurrentKey returns the quoted key of the innermost object member being
+// parsed, as found in the input, or nil if there is none. The returned slice
+// aliases the parser's buffer, and is only valid until the next call to Feed.
+func (p *Parser) CurrentKey() []byte {
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		s := p.stack[i]
+		if s.name == pObject {
+			if s.keyEnd == 0 {
+				return nil
+			}
+			return p.data[s.keyStart:s.keyEnd]
+		}
+	}
+	return nil
+}
+
+// SetKeyCallback registers fn to be called with each object key as soon as
+// it is read, quoted and as found in the input. The slice passed to fn
+// aliases the parser's buffer and must be copied if retained. An error
+// returned by fn aborts parsing and is returned by Feed.
+func (p *Parser) SetKeyCallback(fn func(key []byte) error) {
+	p.keyCallback = fn
+}
+
+// SetRawNumberCallback registers fn to be called with each number, at any
+// depth, as soon as it is read, before it is reported or returned. fn
+// receives the bytes of the number exactly as validated, and may return a
+// replacement for them, which is used as is, without being validated; nil
+// keeps the number unchanged. The slice passed to fn aliases the parser's
+// buffer and must be copied if retained. An error returned by fn aborts
+// parsing and is returned by Feed.
+func (p *Parser) SetRawNumberCallback(fn func(raw []byte) (replacement []byte, err error)) {
+	p.numberCallback = fn
+}
+
+func (p *Parser) rewriteNumber(s state) {
+	if p.hookErr != nil {
+		return
+	}
+	replacement, err := p.numberCallback(p.data[s.position:])
+	if err != nil {
+		p.hookErr = err
+		return
+	}
+	if replacement != nil {
+		p.data = append(p.data[:s.position], replacement...)
+	}
+}
+
+// quoteNumber wraps the number parsed by s in quotes, for NumbersAsStrings.
+func (p *Parser) quoteNumber(s state) {
+	p.data = append(p.data, 0, quote)
+	copy(p.data[s.position+1:], p.data[s.position:len(p.data)-2])
+	p.data[s.position] = quote
+}
+
+// SetErrorFormatter registers fn to produce the message returned by Error for
+// the SyntaxErrors reported by the parser, in place of the default format.
+// Passing nil restores the default.
+func (p *Parser) SetErrorFormatter(fn func(e SyntaxError) string) {
+	p.errFormatter = fn
+}
+
+// ValidateAt registers fn to be called with the bytes of every value found
+// at the given JSON Pointer, as soon as the value is fully parsed. Many
+// validators may be registered, for the same or different paths. An error
+// returned by fn aborts parsing, and is returned wrapped in a
+// ValidationError.
+func (p *Parser) ValidateAt(path string, fn func(value []byte) error) {
+	if p.validators == nil {
+		p.validators = map[string][]func([]byte) error{}
+	}
+	p.validators[path] = append(p.validators[path], fn)
+}
+
+// SetMaxDepthAt limits how deeply arrays and objects may be nested within the
+// value at the given JSON Pointer, in place of MaxDepth. Depth is counted from
+// the top-level value, as for MaxDepth, and zero means unlimited. When the
+// pointers of several limits contain a value, the longest one applies: with
+// limits at "/tree" and "/tree/leaf", the latter applies within /tree/leaf,
+// and the former elsewhere within /tree.
+func (p *Parser) SetMaxDepthAt(pointer string, max int) {
+	if p.depthLimits == nil {
+		p.depthLimits = map[string]int{}
+	}
+	p.depthLimits[pointer] = max
+}
+
+// checkDepth checks whether a container may begin at the current depth.
+func (p *Parser) checkDepth() error {
+	path := ""
+	if len(p.depthLimits) > 0 {
+		path = p.Path()
+	}
+	if max, at := p.depthLimitAt(path); max > 0 && p.depth >= max {
+		return &LimitError{Limit: "MaxDepth", Max: max, Offset: p.offset - 1, Path: at}
+	}
+	return nil
+}
+
+// depthLimitAt returns the depth limit applying to the value at path, and the
+// pointer it was set at through SetMaxDepthAt, if any.
+func (p *Parser) depthLimitAt(path string) (max int, at string) {
+	max, found := p.limits().MaxDepth, false
+	for pointer, m := range p.depthLimits {
+		if (!found || len(pointer) > len(at)) && pointerContains(pointer, path) {
+			max, at, found = m, pointer, true
+		}
+	}
+	return max, at
+}
+
+// rawFrame is a container opened within a value spliced by FeedRaw.
+type rawFrame struct {
+	path   string
+	object bool
+	index  int
+	key    string
+}
+
+// checkRawDepth walks the containers of value, about to be spliced by
+// FeedRaw, enforcing depth limits as if it was fed byte by byte, and records
+// the deepest nesting it reaches.
+func (p *Parser) checkRawDepth(value []byte) error {
+	var frames []rawFrame
+	depth, deepest, expectKey := p.depth, p.depth, false
+	for i := 0; i < len(value); i++ {
+		switch b := value[i]; b {
+		case quote:
+			start := i
+			for i++; value[i] != quote; i++ {
+				if value[i] == '\\' {
+					i++
+				}
+			}
+			if expectKey && len(p.depthLimits) > 0 {
+				key, err := p.Unescape(value[start : i+1])
+				if err != nil {
+					key = string(value[start+1 : i])
+				}
+				frames[len(frames)-1].key = key
+			}
+			expectKey = false
+		case leftSquared, leftCurly:
+			path := ""
+			if len(p.depthLimits) > 0 {
+				switch {
+				case len(frames) == 0:
+					path = p.Path()
+				case frames[len(frames)-1].object:
+					f := frames[len(frames)-1]
+					path = f.path + "/" + escapePointer(f.key)
+				default:
+					f := frames[len(frames)-1]
+					path = f.path + "/" + strconv.Itoa(f.index)
+				}
+			}
+			if max, at := p.depthLimitAt(path); max > 0 && depth >= max {
+				return &LimitError{Limit: "MaxDepth", Max: max, Offset: p.offset + uint64(i), Path: at}
+			}
+			frames = append(frames, rawFrame{path: path, object: b == leftCurly})
+			expectKey = b == leftCurly
+			if depth++; depth > deepest {
+				deepest = depth
+			}
+		case rightSquared, rightCurly:
+			frames = frames[:len(frames)-1]
+			depth--
+		case ',':
+			if f := &frames[len(frames)-1]; f.object {
+				expectKey = true
+			} else {
+				f.index++
+			}
+		}
+	}
+	if deepest > p.maxDepth {
+		p.maxDepth = deepest
+	}
+	if deepest > p.valueMaxDepth {
+		p.valueMaxDepth = deepest
+	}
+	return nil
 }
 
 func (p *Parser) retry() error {
@@ -140,16 +1077,62 @@ func (p *Parser) retry() error {
 }
 
 func (p *Parser) append(b byte) {
+	p.flushWsp()
 	p.data = append(p.data, b)
+	p.curBuffered = true
+	if p.discard {
+		p.trim()
+	}
+}
+
+// trim drops the bytes of the current state that are no longer needed,
+// keeping its first two bytes and its last one: enough for the checks made
+// on the token and on the previous byte. Literal words are kept whole, as
+// they are matched by length.
+func (p *Parser) trim() {
+	if len(p.stack) == 0 {
+		return
+	}
+	switch p.state().name {
+	case pTrue, pFalse, pNull, pNaN, pInfinity, pNegInfinity:
+		return
+	case pString:
+		if p.keepKeys && len(p.stack) > 1 && p.stack[len(p.stack)-2].name == pObjectKey {
+			return
+		}
+	}
+	keep := p.state().position + 2
+	if len(p.data)-keep > 1 {
+		p.data[keep] = p.data[len(p.data)-1]
+		p.data = p.data[:keep+1]
+	}
+}
+
+// flushWsp appends the whitespace pending from NormalizeWhitespace, if any.
+func (p *Parser) flushWsp() {
+	if p.pendingWsp != 0 {
+		p.data = append(p.data, p.pendingWsp)
+		p.pendingWsp = 0
+	}
 }
 
 func (p *Parser) handleWordParsing(word string, b byte) error {
-	idx := len(p.data) - int(p.state().position)
+	idx := len(p.token())
+	if idx == 0 || idx >= len(word) {
+		return p.fail("invalid parser state reading '%s'", word)
+	}
 
-	if b != word[idx] {
+	c := b
+	if p.CaseInsensitiveLiterals && b >= 'A' && b <= 'Z' {
+		switch p.state().name {
+		case pTrue, pFalse, pNull:
+			c = b + 'a' - 'A'
+		}
+	}
+	if c != word[idx] {
 		return p.fail("expected %c (reading '%s'), found `%c' instead", word[idx], word, b)
 	}
-	p.append(b)
+	p.append(c)
 	if idx == len(word)-1 {
 		p.popState()
 	}
@@ -157,8 +1140,310 @@ func (p *Parser) handleWordParsing(word string, b byte) error {
 	return nil
 }
 
+// LastValueSpan returns the offsets of the first byte of the last top-level
+// value returned by the parser, and of the byte following it.
+func (p *Parser) LastValueSpan() (start, end uint64) {
+	return p.lastStart, p.lastEnd
+}
+
+// WhitespaceContext describes where a run of whitespace occurred.
+type WhitespaceContext string
+
+const (
+	// WhitespaceTopLevel is whitespace around top-level values.
+	WhitespaceTopLevel WhitespaceContext = "top level"
+	// WhitespaceBeforeKey is whitespace following '{' or ',' in an object.
+	WhitespaceBeforeKey WhitespaceContext = "before key"
+	// WhitespaceAfterKey is whitespace between an object key and ':'.
+	WhitespaceAfterKey WhitespaceContext = "after key"
+	// WhitespaceAfterColon is whitespace between ':' and a member's value.
+	WhitespaceAfterColon WhitespaceContext = "after colon"
+	// WhitespaceAfterValue is whitespace following a member's value.
+	WhitespaceAfterValue WhitespaceContext = "after value"
+	// WhitespaceBeforeElement is whitespace following '[' or ',' in an
+	// array.
+	WhitespaceBeforeElement WhitespaceContext = "before element"
+	// WhitespaceAfterElement is whitespace following an array element.
+	WhitespaceAfterElement WhitespaceContext = "after element"
+)
+
+// SetWhitespaceCallback registers fn to be called with each run of whitespace
+// found between tokens, along with where it occurred. A run is reported once
+// the byte following it is fed, so whitespace at the very end of the input is
+// never reported. The slice passed to fn is reused, and must be copied if
+// retained. As runs are buffered, a callback costs memory and time; it should
+// only be set when needed.
+func (p *Parser) SetWhitespaceCallback(fn func(ws []byte, context WhitespaceContext)) {
+	p.whitespaceCallback = fn
+}
+
+func (p *Parser) whitespaceContext() WhitespaceContext {
+	if len(p.stack) == 0 {
+		return WhitespaceTopLevel
+	}
+	switch p.state().name {
+	case pArray:
+		if prev := p.prevRelByte(); prev == '[' || prev == ',' {
+			return WhitespaceBeforeElement
+		}
+		return WhitespaceAfterElement
+	case pObjectKey:
+		if p.prevByte() == quote {
+			return WhitespaceAfterKey
+		}
+		return WhitespaceBeforeKey
+	case pObjectValue:
+		if p.prevRelByte() == ':' {
+			return WhitespaceAfterColon
+		}
+		return WhitespaceAfterValue
+	default:
+		return WhitespaceBeforeKey
+	}
+}
+
+// SetValueCallback registers fn to be called by FeedBytes for each top-level
+// value completed, along with the offsets of its first byte and of the byte
+// following it. fn receives a copy of the value, which it may retain. An
+// error returned by fn aborts FeedBytes and is returned by it.
+func (p *Parser) SetValueCallback(fn func(value []byte, start, end uint64) error) {
+	p.valueCallback = fn
+}
+
+// FeedBytes feeds each byte in data to the parser, passing every completed
+// top-level value to the callback registered with SetValueCallback. Without
+// a callback, values are only validated.
+func (p *Parser) FeedBytes(data []byte) error {
+	for _, b := range data {
+		v, err := p.Feed(b)
+		if err != nil {
+			return err
+		}
+		if v != nil && p.valueCallback != nil {
+			value := append([]byte(nil), v...)
+			if err = p.valueCallback(value, p.lastStart, p.lastEnd); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FeedBytesFunc feeds the bytes in data to the parser, calling fn for each
+// top-level value completed, until fn returns stop or an error. It returns
+// how many bytes of data were fed, so a caller that stopped may resume with
+// data[consumed:]. The byte completing a value is always consumed, even when
+// it merely terminates a top-level number. The slice passed to fn aliases the
+// parser's buffer, and must be copied if retained. When parsing fails,
+// consumed excludes the offending byte.
+func (p *Parser) FeedBytesFunc(data []byte, fn func(value []byte) (stop bool, err error)) (consumed int, err error) {
+	for i, b := range data {
+		v, err := p.Feed(b)
+		if err != nil {
+			return i, err
+		}
+		if v == nil {
+			continue
+		}
+		stop, err := fn(v)
+		if stop || err != nil {
+			return i + 1, err
+		}
+	}
+	return len(data), nil
+}
+
+// Errors returns the syntax errors collected while CollectErrors is set.
+func (p *Parser) Errors() []SyntaxError {
+	return p.errs
+}
+
+// FeedRaw splices value, a complete value of the given kind that was already
+// validated by other means, into the stream without parsing it. It must be
+// called where an array element or an object member value is expected, and
+// returns a *SyntaxError at the offset value would begin otherwise; top-level
+// values must be fed normally. value is trusted to be valid JSON, and only
+// its first byte is checked against kind, though the arrays and objects it
+// holds count towards MaxDepth and SetMaxDepthAt limits. Errors raised once value was
+// accepted, such as those of limits or validators, are kept like those of
+// Feed, and returned by every further call until the parser is reset.
+func (p *Parser) FeedRaw(value []byte, kind ValueType) error {
+	if p.err != nil {
+		return p.err
+	}
+	if len(value) == 0 {
+		return p.syntaxError("FeedRaw requires a non-empty value", p.offset)
+	}
+	if t, ok := p.valueTypeOf(value[0]); !ok || t != kind {
+		return p.syntaxError(fmt.Sprintf("FeedRaw value does not start like a %s", kind), p.offset)
+	}
+
+	if len(p.stack) == 0 {
+		return p.syntaxError("FeedRaw requires an enclosing array or object", p.offset)
+	}
+	prevRel := p.prevRelByte()
+	switch s := p.state().name; {
+	case s == pArray && (prevRel == '[' || prevRel == ','):
+		p.stack[len(p.stack)-1].index++
+	case s == pObjectValue && prevRel == ':':
+	default:
+		return p.syntaxError("a value is not allowed here", p.offset)
+	}
+
+	// From here on, the parser's state has changed: errors are kept, as
+	// done by Feed.
+	if err := p.spliceRaw(value, kind); err != nil {
+		p.err = err
+		return err
+	}
+	return nil
+}
+
+// spliceRaw appends value, validated by FeedRaw, to the current value.
+func (p *Parser) spliceRaw(value []byte, kind ValueType) error {
+	if err := p.countNode(); err != nil {
+		return err
+	}
+	if p.schema != nil {
+		if err := p.checkSchemaType(kind); err != nil {
+			return err
+		}
+	}
+	if kind == Array || kind == Object {
+		if err := p.checkRawDepth(value); err != nil {
+			return err
+		}
+	}
+	p.flushWsp()
+	if max := p.limits().MaxValueBytes; max > 0 && len(p.data)+len(value) > max {
+		return p.limit("MaxValueBytes", max)
+	}
+	if p.tee != nil {
+		if err := p.teeWrite(value); err != nil {
+			return err
+		}
+	}
+	start := len(p.data)
+	p.data = append(p.data, value...)
+	p.offset += uint64(len(value))
+	p.wsRun = 0
+	p.valueCompleted(state{name: rawStates[kind], position: start})
+	if err := p.hookErr; err != nil {
+		p.hookErr = nil
+		return err
+	}
+	return nil
+}
+
+// rawStates maps the kinds of values spliced by FeedRaw to the state that would
+// have parsed them.
+var rawStates = map[ValueType]parserState{
+	Null:   pNull,
+	Bool:   pTrue,
+	Number: pNumber,
+	String: pString,
+	Array:  pArray,
+	Object: pObject,
+}
+
+// Feed feeds a single byte to the parser, returning a value once it is
+// complete. Once Feed returns an error, every further call returns the same
+// error until Reset or SkipToNextValue is called.
 func (p *Parser) Feed(b byte) ([]byte, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tee != nil {
+		if err := p.teeByte(b); err != nil {
+			p.err = err
+			return nil, err
+		}
+	}
+	v, err := p.feedCollecting(b)
+	if err != nil {
+		p.err = err
+	}
+	return v, err
+}
+
+func (p *Parser) feedCollecting(b byte) ([]byte, error) {
+	if !p.CollectErrors {
+		return p.feed(b)
+	}
+
+	if p.skipping {
+		if !p.canBeginValue(b) {
+			p.offset++
+			return nil, nil
+		}
+		p.skipping = false
+	}
+
+	v, err := p.feed(b)
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) {
+		p.errs = append(p.errs, *syntaxErr)
+		p.data = p.data[:0]
+		p.stack = p.stack[:0]
+		p.depth = 0
+		p.pendingWsp = 0
+		p.escaped = false
+		p.limitsSet = false
+		p.skipping = true
+		return nil, nil
+	}
+	return v, err
+}
+
+func (p *Parser) feed(b byte) ([]byte, error) {
+	if p.offset == math.MaxUint64 {
+		return nil, p.fail("input offset overflow")
+	}
+	p.offset++
+	p.cur, p.curBuffered = b, false
+	p.afterWsp = p.wsRun > 0
+	if p.NDJSON && b == '\n' && len(p.stack) > 0 && (len(p.stack) > 1 || p.state().name != pNumber) {
+		return nil, p.fail("unexpected newline within a record")
+	}
+	if isWsp(b) && (len(p.stack) == 0 || p.state().name != pString) {
+		p.wsRun++
+		if p.MaxConsecutiveWhitespace > 0 && p.wsRun > p.MaxConsecutiveWhitespace {
+			return nil, p.limit("MaxConsecutiveWhitespace", p.MaxConsecutiveWhitespace)
+		}
+		if p.NormalizeWhitespace && len(p.stack) > 0 && p.pendingWsp != '\n' {
+			if b == '\n' {
+				p.pendingWsp = '\n'
+			} else {
+				p.pendingWsp = ' '
+			}
+		}
+		if p.whitespaceCallback != nil {
+			p.wsBuf = append(p.wsBuf, b)
+		}
+		if p.indentFn != nil {
+			p.lintIndent(b, true)
+		}
+	} else {
+		if p.indentFn != nil {
+			p.lintIndent(b, false)
+		}
+		p.wsRun = 0
+		if len(p.wsBuf) > 0 {
+			p.whitespaceCallback(p.wsBuf, p.whitespaceContext())
+			p.wsBuf = p.wsBuf[:0]
+		}
+	}
+
 	if len(p.stack) == 0 {
+		if p.held != nil {
+			return p.lineEnd(b)
+		}
+		if ok, err := p.skipBOM(b); ok {
+			return nil, err
+		}
+		if ok, err := p.separator(b); ok {
+			return nil, err
+		}
 		return nil, p.parseValue(b)
 	}
 
@@ -166,11 +1451,18 @@ func (p *Parser) Feed(b byte) ([]byte, error) {
 		var e error
 		for {
 			if len(p.stack) == 0 {
+				if isWsp(b) || (b == ',' && p.AllowTopLevelCommas) {
+					// A top-level number was terminated by whitespace
+					// or a separator
+					e = nil
+					break
+				}
 				e = p.fail("unexpected character '%c', as the parser state is not ready to read it", b)
 				break
 			}
 
-			switch p.state().name {
+			s := p.state().name
+			switch s {
 			case pFalse:
 				e = p.parseFalse(b)
 			case pTrue:
@@ -189,9 +1481,21 @@ func (p *Parser) Feed(b byte) ([]byte, error) {
 				e = p.parseObjectKey(b)
 			case pObjectValue:
 				e = p.parseObjectValue(b)
+			case pNaN:
+				e = p.parseNaN(b)
+			case pInfinity:
+				e = p.parseInfinity(b)
+			case pNegInfinity:
+				e = p.parseNegInfinity(b)
 			default:
 				e = fmt.Errorf("bug: Unexpected parser state %#v", p.state())
 			}
+			if p.hookErr != nil && (e == nil || e == retryError) {
+				e, p.hookErr = p.hookErr, nil
+			}
+			if e == retryError && p.tracer != nil {
+				p.trace(TraceRetry, pNone, s)
+			}
 			if e != retryError {
 				break
 			}
@@ -203,22 +1507,178 @@ func (p *Parser) Feed(b byte) ([]byte, error) {
 		return nil, err
 	}
 
+	if max := p.limits().MaxValueBytes; max > 0 && len(p.data) > max {
+		return nil, p.limit("MaxValueBytes", max)
+	}
+
 	if len(p.stack) == 0 {
 		// last state was popped, we got a successful parse.
-		data := p.data
-		p.data = p.data[:0]
-		return data, nil
+		end := p.offset
+		if isWsp(b) || b == ',' {
+			// Only numbers are terminated by whitespace or separators,
+			// which are not part of the value.
+			end--
+		}
+		v := p.complete(end)
+		if _, err := p.separator(b); err != nil {
+			return nil, err
+		}
+		if p.LineTerminated && b != '\n' {
+			p.held = v
+			return nil, nil
+		}
+		return v, nil
 	}
 
 	return nil, nil
 }
 
+// complete is called once a top-level value is fully parsed, and returns its
+// bytes.
+func (p *Parser) complete(end uint64) []byte {
+	data := p.data
+	p.data = p.data[:0]
+	p.pendingWsp = 0
+	p.lastStart, p.lastEnd = p.valueStart, end
+	p.lastType = p.valueType
+	p.lastNumberKind = p.numberKind
+	p.lastMaxDepth = p.valueMaxDepth
+	p.lastSep = p.valueSep
+	p.valueSeen, p.sepSeen, p.commaSeen = true, false, false
+	p.bomSeen = false
+	p.valueIndent = 0
+	p.limitsSet = false
+	return data
+}
+
+var bom = [...]byte{0xEF, 0xBB, 0xBF}
+
+// skipBOM consumes b when it is part of a byte order mark allowed before a
+// top-level value, returning whether it did.
+func (p *Parser) skipBOM(b byte) (bool, error) {
+	if p.bomRead == 0 {
+		if b != bom[0] || p.bomSeen || !(p.SkipBOMPerValue || p.SkipBOM && p.offset == 1) {
+			return false, nil
+		}
+	} else if b != bom[p.bomRead] {
+		return true, p.fail("invalid byte order mark")
+	}
+	p.bomRead++
+	if p.bomRead == len(bom) {
+		p.bomRead, p.bomSeen = 0, true
+	}
+	return true, nil
+}
+
+// separator records b when it separates top-level values, returning whether
+// it was consumed as such.
+func (p *Parser) separator(b byte) (bool, error) {
+	switch {
+	case b == '\n':
+		p.sepSeen = true
+	case b == ',' && p.AllowTopLevelCommas:
+		if !p.valueSeen || p.commaSeen {
+			return true, p.fail("unexpected ',' between top-level values")
+		}
+		p.sepSeen, p.commaSeen = true, true
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// lineEnd handles b following a value held until the end of its line, with
+// LineTerminated.
+func (p *Parser) lineEnd(b byte) ([]byte, error) {
+	switch {
+	case b == '\n':
+		v := p.held
+		p.held = nil
+		p.sepSeen = true
+		return v, nil
+	case isWsp(b):
+		return nil, nil
+	default:
+		return nil, p.fail("unexpected '%c', expected a newline after value", b)
+	}
+}
+
+// emptyInputMsg is the message of errors reporting input holding no value at
+// all, such as an empty string or whitespace alone.
+const emptyInputMsg = "empty input, expected a JSON value"
+
+// finish signals the end of input to the parser, returning a pending
+// top-level number, if any.
+func (p *Parser) finish() ([]byte, error) {
+	if p.held != nil {
+		v := p.held
+		p.held = nil
+		return v, nil
+	}
+	if len(p.stack) == 0 {
+		if !p.valueSeen {
+			return nil, p.syntaxError(emptyInputMsg, p.offset)
+		}
+		return nil, p.syntaxError("unexpected end of input", p.offset)
+	}
+	if len(p.stack) == 1 && p.state().name == pNumber {
+		if isDigit(p.prevByte()) {
+			p.popState()
+			if err := p.hookErr; err != nil {
+				p.hookErr = nil
+				return nil, err
+			}
+			return p.complete(p.offset), nil
+		}
+	}
+	return nil, p.syntaxError("unexpected end of input", p.offset)
+}
+
 func (p *Parser) parseValue(b byte) error {
 	if isWsp(b) {
 		return nil
 	}
+	p.flushWsp()
+	if p.CaseInsensitiveLiterals {
+		b = p.foldLiteral(b)
+	}
+
+	if len(p.stack) == 0 {
+		t, ok := p.valueTypeOf(b)
+		if ok && p.RequireContainerRoot && t != Object && t != Array {
+			return p.fail("top-level value must be an object or an array, found a %s", t)
+		}
+		if ok && len(p.AllowedTopLevelTypes) > 0 && !p.topLevelAllowed(t) {
+			return p.fail("top-level %s values are not allowed", t)
+		}
+		if p.NDJSON && p.valueSeen && !p.sepSeen {
+			return p.fail("expected a newline between records")
+		}
+		p.valueType = t
+		p.numberKind = Integer
+		p.valueStart = p.offset - 1
+		p.valueSep = p.sepSeen
+		p.resetValueLimits()
+	}
+
+	if err := p.countNode(); err != nil {
+		return err
+	}
+
+	if p.schema != nil {
+		if err := p.checkSchemaStart(b); err != nil {
+			return err
+		}
+	}
+
+	if b == leftCurly || b == leftSquared {
+		if err := p.checkDepth(); err != nil {
+			return err
+		}
+	}
 
 	p.data = append(p.data, b)
+	p.curBuffered = true
 	if b == 't' {
 		p.pushState(pTrue)
 	} else if b == 'f' {
@@ -229,76 +1689,223 @@ func (p *Parser) parseValue(b byte) error {
 		p.pushState(pString)
 	} else if b == leftCurly {
 		p.pushState(pObject)
+		if p.tokenFn != nil {
+			p.tokenFn(Token{Kind: TokenBeginObject, Type: Object, Offset: p.offset - 1})
+		}
 	} else if b == leftSquared {
 		p.pushState(pArray)
-	} else if b == '-' || (b >= '0' && b <= '9') {
+		if p.tokenFn != nil {
+			p.tokenFn(Token{Kind: TokenBeginArray, Type: Array, Offset: p.offset - 1})
+		}
+	} else if b == '-' || isDigit(b) {
+		p.seenDot, p.seenExp = false, false
 		p.pushState(pNumber)
+	} else if b == 'N' && p.AllowNonFiniteNumbers {
+		p.numberKind = Float
+		p.pushState(pNaN)
+	} else if b == 'I' && p.AllowNonFiniteNumbers {
+		p.numberKind = Float
+		p.pushState(pInfinity)
+	} else if b == '_' && p.AllowDigitSeparators {
+		return p.fail("unexpected '_', digit separator must follow a digit")
 	} else {
-		return p.fail("expected t, f, n, \", {, [, -, or a number from 0-9, got `%c'", b)
+		return p.fail("%s, got `%c'", p.expectedValue(), b)
 	}
 
 	return nil
 }
 
+// resetValueLimits clears the counters bounding a single top-level value,
+// once a new one begins.
+func (p *Parser) resetValueLimits() {
+	p.valueMaxDepth = 0
+	p.nodes = 0
+	if p.distinct != nil {
+		p.distinct.reset()
+	}
+}
+
+// countNode counts a value beginning within the current top-level value,
+// failing once it exceeds MaxTotalNodes.
+func (p *Parser) countNode() error {
+	p.nodes++
+	if max := p.limits().MaxTotalNodes; max > 0 && p.nodes > max {
+		return p.limit("MaxTotalNodes", max)
+	}
+	return nil
+}
+
+// expectedValue describes the value expected by the current state, for use
+// in error messages.
+func (p *Parser) expectedValue() string {
+	if len(p.stack) == 0 {
+		return "expected a JSON value"
+	}
+	switch p.state().name {
+	case pArray:
+		return "expected an array element"
+	case pObjectValue:
+		return "expected a value after ':' in object"
+	default:
+		return "expected a value"
+	}
+}
+
+func (p *Parser) topLevelAllowed(t ValueType) bool {
+	for _, v := range p.AllowedTopLevelTypes {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// foldLiteral lowercases b when it begins true, false or null in uppercase,
+// for CaseInsensitiveLiterals.
+func (p *Parser) foldLiteral(b byte) byte {
+	switch b {
+	case 'T', 'F':
+		return b + 'a' - 'A'
+	case 'N':
+		if !p.AllowNonFiniteNumbers {
+			return 'n'
+		}
+	}
+	return b
+}
+
 func (p *Parser) parseFalse(b byte) error { return p.handleWordParsing("false", b) }
 func (p *Parser) parseTrue(b byte) error  { return p.handleWordParsing("true", b) }
 func (p *Parser) parseNull(b byte) error  { return p.handleWordParsing("null", b) }
 
+func (p *Parser) parseNaN(b byte) error         { return p.handleWordParsing("NaN", b) }
+func (p *Parser) parseInfinity(b byte) error    { return p.handleWordParsing("Infinity", b) }
+func (p *Parser) parseNegInfinity(b byte) error { return p.handleWordParsing("-Infinity", b) }
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
 func (p *Parser) parseNumber(b byte) error {
 	prevRel := p.prevRelByte()
-	prevParse := p.data[p.state().position:]
+	prevParse := p.token()
+	if prevRel == '_' && !isDigit(b) {
+		return p.fail("unexpected '%c', digit separator '_' must be followed by a digit", b)
+	}
 	switch b {
-	case '-':
-		if prevRel != 0x00 && prevRel != 'e' && prevRel != 'E' {
-			return p.fail("unexpected '-'")
+	case '_':
+		if !p.AllowDigitSeparators {
+			return p.fail("unexpected '_'")
 		}
-		p.append(b)
-		return nil
-	case '+':
+		if !isDigit(prevRel) {
+			return p.fail("unexpected '_', digit separator must follow a digit")
+		}
+		if string(prevParse) == "-0" || string(prevParse) == "0" {
+			return p.fail("invalid number format, leading zeros are not allowed")
+		}
+	case '-', '+':
 		if prevRel != 'e' && prevRel != 'E' {
-			return p.fail("unexpected '+'")
+			return p.fail("unexpected '%c'", b)
 		}
-		p.append(b)
-		return nil
 	case '.':
-		if strings.ContainsAny(string(prevParse), ".eE") ||
-			prevRel == '-' || string(prevParse) == "0" {
-			return p.fail("unexpected '.'")
+		if p.seenExp {
+			return p.fail("unexpected '.', fractions are not allowed in exponents")
 		}
-		p.append(b)
-		return nil
+		if p.seenDot {
+			return p.fail("unexpected '.', number already has a fractional part")
+		}
+		if !isDigit(prevRel) {
+			return p.fail("unexpected '.', expected a number")
+		}
+		p.seenDot = true
+		p.numberKind = Float
 	case 'e', 'E':
-		if prevRel < '0' || prevRel > '9' {
+		if p.ForbidExponents {
+			return p.fail("exponent notation not allowed")
+		}
+		if p.seenExp {
+			return p.fail("unexpected '%c', number already has an exponent", b)
+		}
+		if !isDigit(prevRel) {
 			return p.fail("unexpected '%c', expected a number", b)
 		}
-		p.append(b)
-		return nil
-	case ']', '}', ',', '\r', '\n', ' ', '\t':
-		if prevRel == 'e' || prevRel == 'E' || prevRel == '+' || prevRel == '-' || prevRel == '.' {
+		p.seenExp = true
+		p.numberKind = Float
+	case ']', '}', ',', '\r', '\n', ' ', '\t', quote:
+		if !isDigit(prevRel) {
 			return p.fail("unexpected '%c', expected a number", b)
 		}
 		return p.retry()
-	}
+	default:
+		if b == 'I' && p.AllowNonFiniteNumbers && len(prevParse) == 1 && prevRel == '-' {
+			p.numberKind = Float
+			p.replaceState(pNegInfinity)
+			return retryError
+		}
 
-	// Otherwise we need a number
-	if b < '0' || b > '9' {
-		return p.fail("unexpected '%c'", b)
-	}
+		// Otherwise we need a number
+		if !isDigit(b) {
+			return p.fail("unexpected '%c'", b)
+		}
 
-	if string(prevParse) == "-0" || string(prevParse) == "0" {
-		return p.fail("invalid number format")
+		if string(prevParse) == "-0" || string(prevParse) == "0" {
+			return p.fail("invalid number format, leading zeros are not allowed")
+		}
 	}
 
+	// The length is counted in input bytes, as the token may be trimmed
+	if max := p.limits().MaxNumberLen; max > 0 && p.offset-p.state().offset > uint64(max) {
+		return p.limit("MaxNumberLen", max)
+	}
 	p.append(b)
 	return nil
 }
 
 func (p *Parser) parseString(b byte) error {
-	prevRel := p.prevRelByte()
+	if b < 0x20 && !(p.AllowUnescapedNewlinesInStrings && (b == '\n' || b == '\r')) {
+		return p.fail("unescaped control character %#02x in string", b)
+	}
+	if p.ValidateUTF8 {
+		if err := p.checkUTF8(b); err != nil {
+			return err
+		}
+	}
+	if p.ValidateEscapes || p.NormalizeEscapes {
+		if err := p.checkEscape(b); err != nil {
+			return err
+		}
+	}
+	closing := b == quote && !p.escaped
+	p.escaped = b == '\\' && !p.escaped
+	if !closing {
+		lim := p.limits()
+		name, max := "MaxStringLen", lim.MaxStringLen
+		if lim.MaxKeyLen > 0 && len(p.stack) > 1 && p.stack[len(p.stack)-2].name == pObjectKey {
+			name, max = "MaxKeyLen", lim.MaxKeyLen
+		}
+		if max > 0 && len(p.token()) > max {
+			return p.limit(name, max)
+		}
+	}
 	p.append(b)
-	if b == quote && prevRel != '\\' {
+	if closing {
+		start := p.state().position
+		n := len(p.token()) - 2
+		if p.NormalizeEscapes && !p.discard {
+			if err := p.normalizeEscapes(start); err != nil {
+				return err
+			}
+		}
 		p.popState()
-		return nil
+		if len(p.stack) > 0 && p.state().name == pObjectKey {
+			if n > p.maxKeyLen {
+				p.maxKeyLen = n
+			}
+			return p.keyCompleted(start)
+		}
+		if n > p.maxStringLen {
+			p.maxStringLen = n
+		}
 	}
 	return nil
 }
@@ -308,22 +1915,77 @@ func (p *Parser) parseArray(b byte) error {
 		return nil
 	}
 	prevRel := p.prevRelByte()
+	if b == rightCurly && prevRel != ',' {
+		return p.mismatchedBracket(b)
+	}
 
 	if b == rightSquared && prevRel != ',' {
 		p.append(b)
 		p.popState()
 		return nil
+	} else if b == rightSquared {
+		return p.fail("trailing comma not allowed")
 	} else if b == ',' && prevRel != '[' && prevRel != ',' {
 		p.append(b)
 		return nil
+	} else if b == ',' {
+		if !p.AllowElision {
+			return p.fail("empty array element not allowed")
+		}
+		// An elided element is equivalent to null
+		if err := p.countNode(); err != nil {
+			return err
+		}
+		p.stack[len(p.stack)-1].index++
+		if err := p.elided(); err != nil {
+			return err
+		}
+		p.data = append(p.data, ',')
+		return nil
 	}
 	if prevRel == '[' || prevRel == ',' {
+		p.stack[len(p.stack)-1].index++
+		return p.parseValue(b)
+	}
+	if p.AllowWhitespaceSeparatedElements && p.afterWsp {
+		// Elements are separated by whitespace alone; normalize the
+		// output by emitting the missing comma, ahead of any pending
+		// whitespace.
+		p.data = append(p.data, ',')
+		p.stack[len(p.stack)-1].index++
 		return p.parseValue(b)
 	}
 
 	return p.fail("expected ',', found `%c' instead", b)
 }
 
+// elided completes the null standing for an elided array element, located at
+// the comma following it, as if it had been read.
+func (p *Parser) elided() error {
+	if p.schema != nil {
+		if err := p.checkSchemaType(Null); err != nil {
+			return err
+		}
+	}
+	p.flushWsp()
+	s := state{name: pNull, position: len(p.data), offset: p.offset - 1}
+	p.data = append(p.data, "null"...)
+	if p.tokenFn != nil {
+		p.emitValueToken(s)
+	}
+	p.valueCompleted(s)
+	return nil
+}
+
+// mismatchedBracket reports b, a closing bracket of the wrong kind for the
+// innermost container.
+func (p *Parser) mismatchedBracket(b byte) error {
+	if b == rightCurly {
+		return p.fail("mismatched bracket: found `}' in an array, expected ']'")
+	}
+	return p.fail("mismatched bracket: found `]' in an object, expected '}'")
+}
+
 func (p *Parser) parseObject(b byte) error {
 	if b == rightCurly {
 		p.append(b)
@@ -341,6 +2003,16 @@ func (p *Parser) parseObjectKey(b byte) error {
 	}
 
 	prev := p.prevByte()
+	if b == rightCurly && prev == ',' {
+		return p.fail("trailing comma not allowed")
+	}
+	if b == rightCurly && prev == leftCurly {
+		// An empty object with whitespace within it
+		return p.retry()
+	}
+	if b == rightSquared && prev == leftCurly {
+		return p.mismatchedBracket(b)
+	}
 	if b != '"' && prev != '"' {
 		// must be opening a string
 		return p.fail("expected '\"', found `%c'", b)
@@ -359,6 +2031,64 @@ func (p *Parser) parseObjectKey(b byte) error {
 	return nil
 }
 
+// keyCompleted is called once the string holding an object key is fully read,
+// start being the position of its opening quote.
+func (p *Parser) keyCompleted(start int) error {
+	obj := &p.stack[len(p.stack)-2]
+	obj.keyStart, obj.keyEnd = start, len(p.data)
+	obj.index++
+
+	if p.tokenFn != nil {
+		raw := p.data[obj.keyStart:obj.keyEnd]
+		p.tokenFn(Token{Kind: TokenKey, Type: String, Value: raw, Offset: p.offset - uint64(len(raw))})
+	}
+
+	if p.keyCallback != nil {
+		if err := p.keyCallback(p.data[obj.keyStart:obj.keyEnd]); err != nil {
+			return err
+		}
+	}
+
+	if p.AllowedKeys != nil && (p.AllowedKeysDepth == 0 || p.AllowedKeysDepth == p.depth) {
+		raw := p.data[obj.keyStart+1 : obj.keyEnd-1]
+		if !p.AllowedKeys[string(raw)] {
+			key := p.decodeKey(*obj)
+			if key == string(raw) || !p.AllowedKeys[key] {
+				return p.fail("key %q is not allowed", key)
+			}
+		}
+	}
+
+	if p.RequireSortedKeys {
+		key := p.decodeKey(*obj)
+		if obj.index > 1 && key <= obj.lastKey {
+			return p.fail("key %q is not sorted after %q", key, obj.lastKey)
+		}
+		obj.lastKey = key
+	}
+
+	if p.limits().MaxDistinctKeys > 0 {
+		if err := p.countDistinctKey(p.decodeKey(*obj)); err != nil {
+			return err
+		}
+	}
+
+	if p.RejectDuplicateKeys {
+		key := p.decodeKey(*obj)
+		if _, ok := obj.keys[key]; ok {
+			raw := p.data[obj.keyStart:obj.keyEnd]
+			err := p.syntaxError(fmt.Sprintf("duplicate key %q", key), p.offset-uint64(len(raw)))
+			err.Key = append([]byte(nil), raw...)
+			return err
+		}
+		if obj.keys == nil {
+			obj.keys = map[string]struct{}{}
+		}
+		obj.keys[key] = struct{}{}
+	}
+	return nil
+}
+
 func (p *Parser) parseObjectValue(b byte) error {
 	if isWsp(b) {
 		return nil
@@ -368,8 +2098,18 @@ func (p *Parser) parseObjectValue(b byte) error {
 	if prevRel != ':' && b == '}' {
 		return p.retry()
 	}
+	if prevRel != ':' && b == rightSquared {
+		return p.mismatchedBracket(b)
+	}
 
 	if prevRel != ':' && b == ',' {
+		obj := &p.stack[len(p.stack)-2]
+		obj.keyStart, obj.keyEnd = 0, 0
+		if p.discard {
+			// Drop the member just read, so that only the opening brace
+			// remains and the object doesn't grow with its members.
+			p.data = p.data[:obj.position+1]
+		}
 		p.append(b)
 		p.replaceState(pObjectKey)
 		return nil
@@ -379,5 +2119,5 @@ func (p *Parser) parseObjectValue(b byte) error {
 		return p.parseValue(b)
 	}
 
-	return p.fail("unexpected `%c'", b)
+	return p.fail("expected ',' or '}' between object members, found `%c'", b)
 }
@@ -1,10 +1,15 @@
 package sjson
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -38,6 +43,20 @@ func doParse(data string) (*Parser, []byte, error) {
 	return p, nil, nil
 }
 
+func feedAll(p *Parser, data string) ([]byte, error) {
+	var out []byte
+	for _, b := range []byte(data) {
+		v, err := p.Feed(b)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			out = v
+		}
+	}
+	return out, nil
+}
+
 func TestFalse(t *testing.T) {
 	out, err := parseAll("false")
 	require.NoError(t, err)
@@ -131,23 +150,1642 @@ func TestSuite(t *testing.T) {
 		data, err := os.ReadFile("fixtures/" + n)
 		require.NoError(t, err)
 		expectedResult := ""
+		expected := Either
 		switch {
 		case strings.HasPrefix(n, "y_"):
 			expectedResult = "parses"
+			expected = Accepted
 		case strings.HasPrefix(n, "n_"):
 			expectedResult = "fails"
+			expected = Rejected
 		case strings.HasPrefix(n, "i_"):
 			expectedResult = "is indifferent to"
 		}
 
 		t.Run(expectedResult+" "+n, func(t *testing.T) {
-			_, err := parseAll(string(data))
-			switch expectedResult {
-			case "parses":
-				require.NoError(t, err)
-			case "fails":
-				require.Error(t, err)
-			}
+			got := Classify(data)
+			assert.True(t, got.Matches(expected), "expected %s, got %s", expected, got)
+		})
+	}
+}
+
+func TestAllowedTopLevelTypes(t *testing.T) {
+	for _, v := range []string{`"string"`, "[1,2]", "true", "null", "1"} {
+		t.Run("rejects "+v, func(t *testing.T) {
+			p := &Parser{AllowedTopLevelTypes: []ValueType{Object}}
+			_, err := p.Feed(v[0])
+			assert.ErrorContains(t, err, "top-level")
+		})
+	}
+
+	out, err := feedAll(&Parser{AllowedTopLevelTypes: []ValueType{Object}}, ` {"a":[1,"b",{}]}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":[1,"b",{}]}`, string(out))
+
+	// Non-finite numbers are only numbers when AllowNonFiniteNumbers is set.
+	_, err = feedAll(&Parser{AllowedTopLevelTypes: []ValueType{Object}}, `NaN`)
+	assert.ErrorContains(t, err, "expected a JSON value, got `N'")
+	_, err = feedAll(&Parser{AllowedTopLevelTypes: []ValueType{Object}, AllowNonFiniteNumbers: true}, `NaN`)
+	assert.ErrorContains(t, err, "top-level number values are not allowed")
+}
+
+func TestErrorPosition(t *testing.T) {
+	_, _, err := doParse("  \n\t[1, 2 x]")
+	assert.ErrorContains(t, err, "at position 10")
+
+	_, _, err = doParse(`   {"a":  "b"   "c"}`)
+	assert.ErrorContains(t, err, "at position 16")
+
+	// Offsets count input bytes, including skipped whitespace and every
+	// byte of multibyte characters.
+	for name, offset := range map[string]uint64{
+		"n_leading_whitespace.json": 20,
+		"n_multibyte_strings.json":  58,
+		"n_crlf_lines.json":         22,
+	} {
+		data, err := os.ReadFile("fixtures/error_offsets/" + name)
+		require.NoError(t, err)
+		_, err = parseSingle(&Parser{}, data)
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, name)
+		assert.Equal(t, offset, syntaxErr.Offset, name)
+	}
+}
+
+func TestLastType(t *testing.T) {
+	tests := map[string]ValueType{"true": Bool, "false": Bool, "null": Null, `"a"`: String, "[1]": Array, `{"a":1.5}`: Object, "12 ": Number}
+	for v, typ := range tests {
+		t.Run(v, func(t *testing.T) {
+			p, _, err := doParse(v)
+			require.NoError(t, err)
+			assert.Equal(t, typ, p.LastType())
+		})
+	}
+}
+
+func TestLastNumberKind(t *testing.T) {
+	tests := map[string]NumberKind{"12 ": Integer, "-3 ": Integer, "1.5 ": Float, "1e3 ": Float, "-2E-1 ": Float}
+	for v, kind := range tests {
+		t.Run(v, func(t *testing.T) {
+			p, _, err := doParse(v)
+			require.NoError(t, err)
+			assert.Equal(t, Number, p.LastType())
+			assert.Equal(t, kind, p.LastNumberKind())
+		})
+	}
+
+	p, _, err := doParse("[1.5] 3 ")
+	require.NoError(t, err)
+	assert.Equal(t, Array, p.LastType())
+}
+
+func TestLastValueMaxDepth(t *testing.T) {
+	p := &Parser{}
+	for in, depth := range map[string]int{
+		`1 `:                         0,
+		`"a"`:                        0,
+		`[]`:                         1,
+		`{"a": 1}`:                   1,
+		`[[], [[]], []]`:             3,
+		`{"a": [{"b": {}}], "c": 1}`: 4,
+		`[[[[[1]]]], 2]`:             5,
+	} {
+		v, err := feedAll(p, in)
+		require.NoError(t, err, in)
+		require.NotNil(t, v, in)
+		assert.Equal(t, depth, p.LastValueMaxDepth(), in)
+	}
+
+	// The depth is tracked per value, unlike MaxDepthReached.
+	p = &Parser{}
+	_, err := feedAll(p, `[[[]]] {"a": {}} true `)
+	require.NoError(t, err)
+	assert.Equal(t, 0, p.LastValueMaxDepth())
+	assert.Equal(t, 3, p.MaxDepthReached())
+
+	var depths []int
+	p = &Parser{}
+	p.SetValueCallback(func([]byte, uint64, uint64) error {
+		depths = append(depths, p.LastValueMaxDepth())
+		return nil
+	})
+	require.NoError(t, p.FeedBytes([]byte(`[[[]]] {"a": {}} [1]`)))
+	assert.Equal(t, []int{3, 2, 1}, depths)
+}
+
+func TestMaxConsecutiveWhitespace(t *testing.T) {
+	feed := func(data string) error {
+		_, err := feedAll(&Parser{MaxConsecutiveWhitespace: 3}, data)
+		return err
+	}
+
+	assert.NoError(t, feed("   [ 1,   2, \"     \" ]   "))
+	assert.ErrorIs(t, feed("[1,    2]"), ErrLimitExceeded)
+	assert.ErrorIs(t, feed("    {}"), ErrLimitExceeded)
+}
+
+func TestNonFiniteNumbers(t *testing.T) {
+	tests := []string{"NaN", "Infinity", "-Infinity", "[NaN,Infinity,-Infinity,-1]", `{"a":-Infinity}`}
+	for _, v := range tests {
+		t.Run("parses "+v, func(t *testing.T) {
+			out, err := feedAll(&Parser{AllowNonFiniteNumbers: true}, v)
+			require.NoError(t, err)
+			assert.Equal(t, v, string(out))
+		})
+		t.Run("rejects "+v+" in strict mode", func(t *testing.T) {
+			_, err := parseAll(v)
+			assert.Error(t, err)
+		})
+	}
+
+	for _, v := range []string{"[-Inf]", "[infinity]", "nan", "-NaN"} {
+		_, err := feedAll(&Parser{AllowNonFiniteNumbers: true}, v)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestCorruptedStateDoesNotPanic(t *testing.T) {
+	p := &Parser{parseState: parseState{data: []byte("t"), stack: []state{{name: pTrue, position: 10}}}}
+	assert.NotPanics(t, func() {
+		_, err := p.Feed('r')
+		assert.Error(t, err)
+	})
+
+	p = &Parser{parseState: parseState{data: []byte("tru"), stack: []state{{name: pTrue, position: -5}}}}
+	assert.NotPanics(t, func() {
+		_, err := p.Feed('e')
+		assert.Error(t, err)
+	})
+}
+
+func TestOffsetOverflow(t *testing.T) {
+	p := &Parser{parseState: parseState{offset: math.MaxUint64}}
+	_, err := p.Feed('1')
+	assert.ErrorContains(t, err, "overflow")
+}
+
+func TestSkipToNextValue(t *testing.T) {
+	p := &Parser{}
+	data := []byte(`{"a": x} ... [1]`)
+	var err error
+	i := 0
+	for ; i < len(data); i++ {
+		if _, err = p.Feed(data[i]); err != nil {
+			break
+		}
+	}
+	require.Error(t, err)
+	assert.Equal(t, 6, i)
+
+	rest := data[i+1:]
+	n := p.SkipToNextValue(rest)
+	assert.Equal(t, "[1]", string(rest[n:]))
+	assert.Equal(t, uint64(len(data)-3), p.Offset())
+
+	out, err := feedAll(p, string(rest[n:]))
+	require.NoError(t, err)
+	assert.Equal(t, "[1]", string(out))
+
+	assert.Equal(t, 3, p.SkipToNextValue([]byte(" }]")))
+	assert.Equal(t, 4, p.SkipToNextValue([]byte(" NaN")))
+	p.AllowNonFiniteNumbers = true
+	assert.Equal(t, 1, p.SkipToNextValue([]byte(" NaN")))
+}
+
+func TestWhitespaceSeparatedElements(t *testing.T) {
+	tests := map[string]string{
+		"[1 2 3]":             "[1,2,3]",
+		"[1, 2 3]":            "[1,2,3]",
+		"[1 ,2\n3 ]":          "[1,2,3]",
+		`["a" "b"	true null]`: `["a","b",true,null]`,
+		"[[1] [2 3] {}]":      "[[1],[2,3],{}]",
+		`{"a":[1 2]}`:         `{"a":[1,2]}`,
+	}
+	for in, expected := range tests {
+		t.Run("parses "+in, func(t *testing.T) {
+			out, err := feedAll(&Parser{AllowWhitespaceSeparatedElements: true}, in)
+			require.NoError(t, err)
+			assert.Equal(t, expected, string(out))
+		})
+		t.Run("rejects "+in+" in strict mode", func(t *testing.T) {
+			_, err := parseAll(in)
+			assert.Error(t, err)
+		})
+	}
+
+	for _, in := range []string{"[1 2,]", "[1,,2]", "[,1 2]"} {
+		_, err := feedAll(&Parser{AllowWhitespaceSeparatedElements: true}, in)
+		assert.Error(t, err, in)
+	}
+
+	// Object members still need commas.
+	optionFixtures(t, "fixtures/whitespace_separated_elements", func() *Parser {
+		return &Parser{AllowWhitespaceSeparatedElements: true}
+	}, "expected ',' or '}' between object members", false)
+}
+
+func TestAllowedKeys(t *testing.T) {
+	allowed := map[string]bool{"id": true, "name": true, "tags": true}
+
+	_, err := feedAll(&Parser{AllowedKeys: allowed}, `{"id":1,"name":"a","tags":[]}`)
+	assert.NoError(t, err)
+
+	_, err = feedAll(&Parser{AllowedKeys: allowed}, `{"n\u0061me":"a"}`)
+	assert.NoError(t, err)
+
+	_, err = feedAll(&Parser{AllowedKeys: allowed}, `{"id":1,"admin":true}`)
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, `key "admin" is not allowed`, syntaxErr.Msg)
+	assert.Equal(t, uint64(14), syntaxErr.Offset)
+
+	_, err = feedAll(&Parser{AllowedKeys: allowed}, `{"id":{"nested":1}}`)
+	assert.Error(t, err)
+
+	_, err = feedAll(&Parser{AllowedKeys: allowed, AllowedKeysDepth: 1}, `{"id":{"nested":1}}`)
+	assert.NoError(t, err)
+
+	_, err = feedAll(&Parser{AllowedKeys: allowed, AllowedKeysDepth: 2}, `[{"other":1}]`)
+	assert.Error(t, err)
+}
+
+func TestElision(t *testing.T) {
+	tests := map[string]string{
+		"[1,,2]":       "[1,null,2]",
+		"[,1]":         "[null,1]",
+		"[1,,,2]":      "[1,null,null,2]",
+		`{"a":[,"b"]}`: `{"a":[null,"b"]}`,
+	}
+	for in, expected := range tests {
+		t.Run("parses "+in, func(t *testing.T) {
+			out, err := feedAll(&Parser{AllowElision: true}, in)
+			require.NoError(t, err)
+			assert.Equal(t, expected, string(out))
+		})
+		t.Run("rejects "+in+" in strict mode", func(t *testing.T) {
+			_, err := parseAll(in)
+			assert.ErrorContains(t, err, "empty array element")
+		})
+	}
+
+	for _, in := range []string{"[1,]", "[,]", "[1,,]"} {
+		_, err := feedAll(&Parser{AllowElision: true}, in)
+		assert.Error(t, err, in)
+	}
+
+	// Elided elements are completed like a null read from the input.
+	tok := NewTokenizer()
+	tok.AllowElision = true
+	var kinds []string
+	for _, b := range []byte(`[1,,2]`) {
+		toks, err := tok.Feed(b)
+		require.NoError(t, err)
+		for _, k := range toks {
+			kinds = append(kinds, fmt.Sprintf("%s %s %s", k.Kind, k.Type, k.Value))
+		}
+	}
+	assert.Equal(t, []string{"begin array array ", "value number 1", "value null null", "value number 2", "end array array "}, kinds)
+
+	var validated []string
+	p := &Parser{AllowElision: true}
+	p.ValidateAt("/1", func(v []byte) error {
+		validated = append(validated, string(v))
+		return errors.New("no nulls")
+	})
+	_, err := feedAll(p, `[1,,2]`)
+	assert.ErrorContains(t, err, "no nulls")
+	assert.Equal(t, []string{"null"}, validated)
+
+	p = &Parser{AllowElision: true}
+	p.SetSchema(NewSchema().Add("/0", Rule{Types: []ValueType{Number}}))
+	_, err = feedAll(p, `[,1]`)
+	assert.ErrorContains(t, err, "expected number, found null")
+
+	// Trailing commas are rejected even with elision, and elided elements
+	// without it.
+	optionFixtures(t, "fixtures/elision", func() *Parser {
+		return &Parser{AllowElision: true}
+	}, "trailing comma not allowed", false)
+	fixtures, err := filepath.Glob("fixtures/elision/y_*.json")
+	require.NoError(t, err)
+	for _, name := range fixtures {
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		_, err = Parse(data)
+		assert.ErrorContains(t, err, "empty array element not allowed", name)
+	}
+}
+
+func TestHighWaterMarks(t *testing.T) {
+	p := &Parser{}
+	_, err := feedAll(p, `{"key":"abc","k":[[["abcdef"]]],"longer_key":""} ["a\"b"]`)
+	require.NoError(t, err)
+	assert.Equal(t, 4, p.MaxDepthReached())
+	assert.Equal(t, 6, p.MaxStringLenReached())
+	assert.Equal(t, 10, p.MaxKeyLenReached())
+
+	p.Reset()
+	assert.Equal(t, 0, p.MaxDepthReached())
+	assert.Equal(t, 0, p.MaxStringLenReached())
+	assert.Equal(t, 0, p.MaxKeyLenReached())
+
+	_, err = feedAll(p, `"a\"b"`)
+	require.NoError(t, err)
+	assert.Equal(t, 4, p.MaxStringLenReached())
+	assert.Equal(t, 0, p.MaxDepthReached())
+}
+
+func TestResetKeepsConfiguration(t *testing.T) {
+	p := &Parser{MaxDepth: 1}
+	_, err := feedAll(p, `[1, [`)
+	require.Error(t, err)
+
+	p.Reset()
+	assert.Equal(t, 1, p.MaxDepth)
+	assert.Equal(t, uint64(0), p.Offset())
+	out, err := feedAll(p, `[1]`)
+	require.NoError(t, err)
+	assert.Equal(t, "[1]", string(out))
+}
+
+func TestResetClearsParseState(t *testing.T) {
+	fresh := func(p *Parser) parseState {
+		return parseState{data: p.data[:0], stack: p.stack[:0], wsBuf: p.wsBuf[:0]}
+	}
+	inputs := []struct {
+		parser Parser
+		input  string
+	}{
+		{Parser{}, `{"a": [1, "b\`},
+		{Parser{}, `[1, 2.5e`},
+		{Parser{}, `{"a": 1} [true, x`},
+		{Parser{MaxDepth: 2}, `[[[`},
+		{Parser{CollectErrors: true}, `[1,] {"a" 1} "x`},
+		{Parser{SkipBOMPerValue: true}, "{} \xEF\xBB"},
+		{Parser{NormalizeWhitespace: true}, "[1,\n  "},
+		{Parser{AllowTopLevelCommas: true}, `1, 2,`},
+		{Parser{RejectDuplicateKeys: true}, `{"a": 1, "a"`},
+	}
+	for _, tt := range inputs {
+		p := tt.parser
+		p.SetWhitespaceCallback(func([]byte, WhitespaceContext) {})
+		_, _ = feedAll(&p, tt.input)
+
+		p.Reset()
+		assert.Equal(t, fresh(&p), p.parseState, tt.input)
+		out, err := feedAll(&p, `{"a":["b\"",1]}`)
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, `{"a":["b\"",1]}`, string(out), tt.input)
+		assert.Equal(t, uint64(15), p.Offset(), tt.input)
+		assert.Equal(t, 2, p.MaxDepthReached(), tt.input)
+		assert.Empty(t, p.Errors(), tt.input)
+	}
+}
+
+func TestResetWith(t *testing.T) {
+	template := &Parser{AllowElision: true}
+	p := &Parser{MaxDepth: 1}
+	_, err := feedAll(p, `[1, [`)
+	require.Error(t, err)
+
+	p.ResetWith(template)
+	assert.Equal(t, 0, p.MaxDepth)
+	assert.True(t, p.AllowElision)
+	out, err := feedAll(p, `[[1,,2]]`)
+	require.NoError(t, err)
+	assert.Equal(t, "[[1,null,2]]", string(out))
+	assert.Empty(t, template.data)
+}
+
+func TestResetWithCopiesConfiguration(t *testing.T) {
+	template := &Parser{AllowedKeys: map[string]bool{"a": true}, AllowedTopLevelTypes: []ValueType{Object}}
+	template.ValidateAt("/a", func([]byte) error { return nil })
+	template.SetMaxDepthAt("/a", 2)
+	template.RegisterEscape('x', 2, nil)
+
+	p := &Parser{}
+	p.ResetWith(template)
+	p.AllowedKeys["b"] = true
+	p.AllowedTopLevelTypes[0] = Array
+	p.ValidateAt("/a", func([]byte) error { return errors.New("rejected") })
+	p.SetMaxDepthAt("/a", 5)
+	p.SetMaxDepthAt("/b", 1)
+	p.RegisterEscape('y', 4, nil)
+
+	assert.Equal(t, map[string]bool{"a": true}, template.AllowedKeys)
+	assert.Equal(t, []ValueType{Object}, template.AllowedTopLevelTypes)
+	assert.Len(t, template.validators["/a"], 1)
+	assert.Equal(t, map[string]int{"/a": 2}, template.depthLimits)
+	assert.Len(t, template.escapes, 1)
+
+	_, err := feedAll(template, `{"a": [1]}`)
+	assert.NoError(t, err)
+}
+
+func TestNumberFormats(t *testing.T) {
+	valid := []string{"[0]", "[-0]", "[0.5]", "[-0.5]", "[10.25]", "[0e1]", "[1E+2]", "[1e-2]", "[-1.5E10]", "[123]"}
+	for _, v := range valid {
+		t.Run("parses "+v, func(t *testing.T) {
+			out, err := parseAll(v)
+			require.NoError(t, err)
+			assert.Equal(t, v, string(out))
+		})
+	}
+
+	invalid := map[string]string{
+		"[1.2.3]":  "already has a fractional part",
+		"[1e2.3]":  "fractions are not allowed in exponents",
+		"[1e5e3]":  "already has an exponent",
+		"[1E2e3]":  "already has an exponent",
+		"[01]":     "leading zeros",
+		"[-01]":    "leading zeros",
+		"[-.5]":    "expected a number",
+		"[1.e3]":   "expected a number",
+		"[1.]":     "expected a number",
+		"[1e]":     "expected a number",
+		"[1e+]":    "expected a number",
+		"[-]":      "expected a number",
+		"[1-2]":    "unexpected '-'",
+		"[1+2]":    "unexpected '+'",
+		"[1.5e+-]": "unexpected '-'",
+	}
+	for v, msg := range invalid {
+		t.Run("rejects "+v, func(t *testing.T) {
+			_, err := parseAll(v)
+			assert.ErrorContains(t, err, msg)
+		})
+	}
+}
+
+func TestNumberParsingDoesNotAllocate(t *testing.T) {
+	p := &Parser{}
+	data := []byte("[-1234.5678e-10, 0.5, 42]")
+	_, err := feedAll(p, string(data))
+	require.NoError(t, err)
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, b := range data {
+			_, _ = p.Feed(b)
+		}
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestValueCallback(t *testing.T) {
+	type value struct {
+		data       string
+		start, end uint64
+	}
+	var values []value
+	p := &Parser{}
+	p.SetValueCallback(func(v []byte, start, end uint64) error {
+		values = append(values, value{string(v), start, end})
+		return nil
+	})
+
+	in := " {\"a\": 1}\n[1, 2] 42 \"s\"true"
+	require.NoError(t, p.FeedBytes([]byte(in[:5])))
+	require.NoError(t, p.FeedBytes([]byte(in[5:])))
+	assert.Equal(t, []value{
+		{`{"a":1}`, 1, 9},
+		{"[1,2]", 10, 16},
+		{"42", 17, 19},
+		{`"s"`, 20, 23},
+		{"true", 23, 27},
+	}, values)
+	for _, v := range values[2:] {
+		assert.Equal(t, v.data, in[v.start:v.end])
+	}
+
+	boom := fmt.Errorf("boom")
+	p = &Parser{}
+	calls := 0
+	p.SetValueCallback(func([]byte, uint64, uint64) error {
+		calls++
+		return boom
+	})
+	assert.ErrorIs(t, p.FeedBytes([]byte("1 2 3 ")), boom)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRequireContainerRoot(t *testing.T) {
+	for _, v := range []string{"true", "42", `"x"`, "null", "-1"} {
+		t.Run("rejects "+v, func(t *testing.T) {
+			p := &Parser{RequireContainerRoot: true}
+			_, err := p.Feed(v[0])
+			var syntaxErr *SyntaxError
+			require.ErrorAs(t, err, &syntaxErr)
+			assert.Equal(t, uint64(0), syntaxErr.Offset)
+			assert.Contains(t, syntaxErr.Msg, "must be an object or an array")
 		})
 	}
+
+	for _, v := range []string{"[1, true]", `{"a": "x"}`} {
+		_, err := feedAll(&Parser{RequireContainerRoot: true}, v)
+		assert.NoError(t, err, v)
+	}
+}
+
+func TestRejectDuplicateKeys(t *testing.T) {
+	_, err := feedAll(&Parser{RejectDuplicateKeys: true}, `{"a": 1, "b": {"a": 2}, "c": [{"a": 3}], "d": {"b": 1, "d": 2}}`)
+	require.NoError(t, err)
+
+	in := `{"id": 1, "name": "x", "id": 2}`
+	_, err = feedAll(&Parser{RejectDuplicateKeys: true}, in)
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Contains(t, err.Error(), `duplicate key "id"`)
+	assert.Equal(t, `"id"`, string(syntaxErr.Key))
+	assert.Equal(t, uint64(strings.LastIndex(in, `"id"`)), syntaxErr.Offset)
+
+	_, err = feedAll(&Parser{RejectDuplicateKeys: true}, `[{"a": 1}, {"b": {}, "b": 2}]`)
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Contains(t, err.Error(), `duplicate key "b"`)
+
+	_, err = feedAll(&Parser{RejectDuplicateKeys: true}, `{"a": 1, "\u0061": 2}`)
+	assert.ErrorContains(t, err, `duplicate key "a"`)
+
+	_, err = parseAll(`{"a": 1, "a": 2}`)
+	assert.NoError(t, err)
+}
+
+func TestCollectErrors(t *testing.T) {
+	p := &Parser{CollectErrors: true}
+	var values []string
+	for _, b := range []byte(`[1, x] {"a": 1} {"b" 2} [true] [1,,2] "end"`) {
+		v, err := p.Feed(b)
+		require.NoError(t, err)
+		if v != nil {
+			values = append(values, string(v))
+		}
+	}
+	assert.Equal(t, []string{`{"a":1}`, `[true]`, `"end"`}, values)
+
+	// Resynchronizing after `[1,,` resumes at `2`, which causes the
+	// following `]` to be reported as well.
+	errs := p.Errors()
+	require.Len(t, errs, 4)
+	assert.Equal(t, uint64(4), errs[0].Offset)
+	assert.Equal(t, uint64(21), errs[1].Offset)
+	assert.Equal(t, uint64(34), errs[2].Offset)
+	assert.Equal(t, uint64(36), errs[3].Offset)
+
+	p.Reset()
+	assert.Empty(t, p.Errors())
+
+	p = &Parser{CollectErrors: true, MaxDepth: 1}
+	_, err := feedAll(p, "[[1]]")
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestValidateAt(t *testing.T) {
+	var ports, names []string
+	p := &Parser{}
+	p.ValidateAt("/config/port", func(v []byte) error {
+		ports = append(ports, string(v))
+		if v[0] == '"' || v[0] == '-' {
+			return fmt.Errorf("port must be a positive number")
+		}
+		return nil
+	})
+	p.ValidateAt("/config/port", func(v []byte) error { return nil })
+	p.ValidateAt("/names/1", func(v []byte) error {
+		names = append(names, string(v))
+		return nil
+	})
+	p.ValidateAt("", func(v []byte) error {
+		if v[0] != '{' {
+			return fmt.Errorf("not an object")
+		}
+		return nil
+	})
+
+	_, err := feedAll(p, `{"config": {"port": 8080}, "names": ["a", ["b"], "c"], "port": "x"}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"8080"}, ports)
+	assert.Equal(t, []string{`["b"]`}, names)
+
+	in := `{"config": {"host": "h", "port": "80"}}`
+	p.Reset()
+	_, err = feedAll(p, in)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "/config/port", validationErr.Path)
+	assert.Equal(t, uint64(strings.Index(in, `"}`)), validationErr.Offset)
+	assert.ErrorContains(t, err, "port must be a positive number")
+
+	p.Reset()
+	_, err = feedAll(p, "[1] ")
+	assert.ErrorContains(t, err, "not an object")
+}
+
+func TestCurrentKey(t *testing.T) {
+	in := `{"a": {"b": [1, 2]}, "c": 3}`
+	p := &Parser{}
+	var keys []string
+	p.SetKeyCallback(func(key []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	seen := map[byte]string{}
+	for _, b := range []byte(in) {
+		_, err := p.Feed(b)
+		require.NoError(t, err)
+		if b >= '0' && b <= '9' {
+			seen[b] = string(p.CurrentKey())
+		}
+	}
+	assert.Equal(t, []string{`"a"`, `"b"`, `"c"`}, keys)
+	assert.Equal(t, map[byte]string{'1': `"b"`, '2': `"b"`, '3': `"c"`}, seen)
+	assert.Nil(t, p.CurrentKey())
+
+	boom := fmt.Errorf("boom")
+	p = &Parser{}
+	p.SetKeyCallback(func([]byte) error { return boom })
+	_, err := feedAll(p, `{"a": 1}`)
+	assert.ErrorIs(t, err, boom)
+}
+
+func BenchmarkKeyHeavyObject(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `"key_number_%d":%d`, i, i)
+	}
+	sb.WriteByte('}')
+	data := []byte(sb.String())
+
+	p := &Parser{}
+	keys := 0
+	p.SetKeyCallback(func(key []byte) error {
+		keys += len(key)
+		return nil
+	})
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if err := p.FeedBytes(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFeedRaw(t *testing.T) {
+	p := &Parser{}
+	var validated []string
+	p.ValidateAt("/items/1", func(v []byte) error {
+		validated = append(validated, string(v))
+		return nil
+	})
+
+	_, err := feedAll(p, `{"items": [1, `)
+	require.NoError(t, err)
+	require.NoError(t, p.FeedRaw([]byte(`{"trusted":[true]}`), Object))
+	_, err = feedAll(p, `, 3], "raw": `)
+	require.NoError(t, err)
+	require.NoError(t, p.FeedRaw([]byte(`"fragment"`), String))
+	out, err := feedAll(p, `}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"items":[1,{"trusted":[true]},3],"raw":"fragment"}`, string(out))
+	assert.Equal(t, []string{`{"trusted":[true]}`}, validated)
+
+	rejected := func(err error, msg string, offset uint64) {
+		t.Helper()
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr)
+		assert.Equal(t, msg, syntaxErr.Msg)
+		assert.Equal(t, offset, syntaxErr.Offset)
+	}
+
+	p = &Parser{}
+	rejected(p.FeedRaw([]byte("1"), Number), "FeedRaw requires an enclosing array or object", 0)
+	_, err = feedAll(p, `[1`)
+	require.NoError(t, err)
+	rejected(p.FeedRaw([]byte("2"), Number), "a value is not allowed here", 2)
+	_, err = feedAll(p, `,`)
+	require.NoError(t, err)
+	rejected(p.FeedRaw([]byte("2"), String), "FeedRaw value does not start like a string", 3)
+	rejected(p.FeedRaw(nil, Number), "FeedRaw requires a non-empty value", 3)
+	rejected(p.FeedRaw([]byte("NaN"), Number), "FeedRaw value does not start like a number", 3)
+	assert.NoError(t, p.FeedRaw([]byte("2"), Number))
+
+	p = &Parser{}
+	_, err = feedAll(p, `{"a"`)
+	require.NoError(t, err)
+	rejected(p.FeedRaw([]byte("2"), Number), "a value is not allowed here", 4)
+
+	// Once a spliced value is rejected, the parser keeps returning the error.
+	errRejected := errors.New("rejected")
+	p = &Parser{}
+	p.ValidateAt("/0", func([]byte) error { return errRejected })
+	_, err = feedAll(p, `[`)
+	require.NoError(t, err)
+	assert.ErrorIs(t, p.FeedRaw([]byte("5"), Number), errRejected)
+	v, err := p.Feed(']')
+	assert.ErrorIs(t, err, errRejected)
+	assert.Nil(t, v)
+	assert.ErrorIs(t, p.FeedRaw([]byte("6"), Number), errRejected)
+
+	var tee bytes.Buffer
+	p = &Parser{MaxValueBytes: 4}
+	p.SetTee(&tee)
+	_, err = feedAll(p, `[`)
+	require.NoError(t, err)
+	assert.ErrorIs(t, p.FeedRaw([]byte(`"abcd"`), String), ErrLimitExceeded)
+	assert.Equal(t, "[", tee.String())
+	assert.Equal(t, "[", string(p.data))
+
+	// Spliced containers count towards the depth reached, and its limits.
+	p = &Parser{}
+	_, err = feedAll(p, `[`)
+	require.NoError(t, err)
+	require.NoError(t, p.FeedRaw([]byte(`[[["]"],1]]`), Array))
+	_, err = feedAll(p, `]`)
+	require.NoError(t, err)
+	assert.Equal(t, 4, p.LastValueMaxDepth())
+	assert.Equal(t, 4, p.MaxDepthReached())
+
+	p = &Parser{MaxDepth: 2}
+	_, err = feedAll(p, `[`)
+	require.NoError(t, err)
+	err = p.FeedRaw([]byte(`[[1]]`), Array)
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "MaxDepth", limitErr.Limit)
+	assert.Equal(t, uint64(2), limitErr.Offset)
+	assert.Equal(t, "[", string(p.data))
+}
+
+func TestFeedAfterError(t *testing.T) {
+	p := &Parser{}
+	_, err := feedAll(p, `[1,}`)
+	require.Error(t, err)
+
+	_, err2 := p.Feed('1')
+	assert.Same(t, err, err2)
+	_, err2 = p.Feed(']')
+	assert.Same(t, err, err2)
+
+	p.Reset()
+	v, err := feedAll(p, `[1]`)
+	require.NoError(t, err)
+	assert.Equal(t, "[1]", string(v))
+}
+
+func TestSkipToNextValueClearsError(t *testing.T) {
+	p := &Parser{}
+	_, err := feedAll(p, `[1,}`)
+	require.Error(t, err)
+
+	data := []byte(` true`)
+	n := p.SkipToNextValue(data)
+	v, err := feedAll(p, string(data[n:]))
+	require.NoError(t, err)
+	assert.Equal(t, "true", string(v))
+}
+
+func TestSetErrorFormatter(t *testing.T) {
+	p := &Parser{}
+	p.SetErrorFormatter(func(e SyntaxError) string {
+		return fmt.Sprintf("column %d: %s", e.Offset+1, e.Msg)
+	})
+	_, err := feedAll(p, `[1,}`)
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "column 4: expected "))
+
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, uint64(3), syntaxErr.Offset)
+
+	p.Reset()
+	p.SetErrorFormatter(nil)
+	_, err = feedAll(p, `[1,}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed parsing stream:")
+}
+
+func TestNormalizeWhitespace(t *testing.T) {
+	cases := map[string]string{
+		"  [1,   2 ,\t3]  ":                "[1, 2 , 3]",
+		"{\n  \"a\" : 1,\n  \"b\": [ ]\n}": "{\n\"a\" : 1,\n\"b\": [ ]\n}",
+		`{"a b":  "c  d"}`:                 `{"a b": "c  d"}`,
+		"[1\r\n,2]":                        "[1\n,2]",
+		`[1,2]`:                            `[1,2]`,
+	}
+	for in, expected := range cases {
+		p := &Parser{NormalizeWhitespace: true}
+		out, err := feedAll(p, in)
+		require.NoError(t, err, in)
+		assert.Equal(t, expected, string(out), in)
+	}
+
+	p := &Parser{NormalizeWhitespace: true, AllowWhitespaceSeparatedElements: true}
+	out, err := feedAll(p, "[1  2]")
+	require.NoError(t, err)
+	assert.Equal(t, "[1, 2]", string(out))
+
+	p = &Parser{NormalizeWhitespace: true, AllowElision: true}
+	out, err = feedAll(p, "[1, ,2]")
+	require.NoError(t, err)
+	assert.Equal(t, "[1, null,2]", string(out))
+
+	p = &Parser{NormalizeWhitespace: true}
+	var values []string
+	for _, b := range []byte("1 \n [ 2 ]") {
+		v, err := p.Feed(b)
+		require.NoError(t, err)
+		if v != nil {
+			values = append(values, string(v))
+		}
+	}
+	assert.Equal(t, []string{"1", "[ 2 ]"}, values)
+}
+
+func TestLastValueHadSeparator(t *testing.T) {
+	separators := func(p *Parser, data string) ([]bool, error) {
+		var seps []bool
+		for _, b := range []byte(data) {
+			v, err := p.Feed(b)
+			if err != nil {
+				return seps, err
+			}
+			if v != nil {
+				seps = append(seps, p.LastValueHadSeparator())
+			}
+		}
+		if len(p.stack) > 0 {
+			if _, err := p.finish(); err != nil {
+				return seps, err
+			}
+			seps = append(seps, p.LastValueHadSeparator())
+		}
+		return seps, nil
+	}
+
+	seps, err := separators(&Parser{}, "{}{} {}\n{}\r\n 1\n2 3")
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false, false, false, true, true, true, false}, seps)
+
+	seps, err = separators(&Parser{AllowTopLevelCommas: true}, "{},{} , []1,2 3")
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false, true, true, false, true, false}, seps)
+
+	_, err = separators(&Parser{}, "1,2")
+	assert.Error(t, err)
+	_, err = separators(&Parser{AllowTopLevelCommas: true}, ",1")
+	assert.Error(t, err)
+	_, err = separators(&Parser{AllowTopLevelCommas: true}, "1,,2")
+	assert.Error(t, err)
+	_, err = separators(&Parser{AllowTopLevelCommas: true}, "[1],\n,2")
+	assert.Error(t, err)
+}
+
+func TestNumbersClosingArrays(t *testing.T) {
+	for _, in := range []string{`[1]`, `[1,2]`, `[0]`, `[1.5e3]`, `[-0]`, `[1,[2]]`, `{"a":[3]}`} {
+		p := &Parser{}
+		for i, b := range []byte(in) {
+			v, err := p.Feed(b)
+			require.NoError(t, err, in)
+			if i < len(in)-1 {
+				require.Nil(t, v, in)
+				continue
+			}
+			assert.Equal(t, in, string(v))
+			assert.Empty(t, p.stack, in)
+		}
+	}
+
+	for _, in := range []string{`[1,2,3`, `[0`, `[1.5e3`, `[1.`, `[1e`} {
+		_, err := Parse([]byte(in))
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, in)
+		assert.Equal(t, "unexpected end of input", syntaxErr.Msg, in)
+		assert.Equal(t, uint64(len(in)), syntaxErr.Offset, in)
+	}
+}
+
+func TestTrailingCommaMessage(t *testing.T) {
+	cases := map[string]uint64{
+		"n_array_trailing_comma_nested.json":  4,
+		"n_object_trailing_comma_nested.json": 12,
+	}
+	for name, offset := range cases {
+		data, err := os.ReadFile("fixtures/" + name)
+		require.NoError(t, err)
+		_, err = Parse(data)
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, name)
+		assert.Equal(t, "trailing comma not allowed", syntaxErr.Msg, name)
+		assert.Equal(t, offset, syntaxErr.Offset, name)
+	}
+
+	for _, in := range []string{`[1,]`, `{"a":1,}`, `[1, ]`, `{"a":1 , }`} {
+		_, err := Parse([]byte(in))
+		require.Error(t, err, in)
+		assert.Contains(t, err.Error(), "trailing comma not allowed", in)
+	}
+
+	p := &Parser{AllowElision: true}
+	_, err := feedAll(p, `[1,]`)
+	assert.ErrorContains(t, err, "trailing comma not allowed")
+}
+
+func TestEmptyArrayElementMessage(t *testing.T) {
+	cases := map[string]uint64{
+		"n_array_double_comma.json": 3,
+		"n_array_only_commas.json":  1,
+		"n_array_just_comma.json":   1,
+	}
+	for name, offset := range cases {
+		data, err := os.ReadFile("fixtures/" + name)
+		require.NoError(t, err)
+		_, err = Parse(data)
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, name)
+		assert.Equal(t, "empty array element not allowed", syntaxErr.Msg, name)
+		assert.Equal(t, offset, syntaxErr.Offset, name)
+	}
+
+	for _, in := range []string{`[1, ,2]`, `[ ,1]`, `{"a": [[], , []]}`, `[1,,]`} {
+		_, err := Parse([]byte(in))
+		assert.ErrorContains(t, err, "empty array element not allowed", in)
+	}
+	_, err := Parse([]byte(`[1, ]`))
+	assert.ErrorContains(t, err, "trailing comma not allowed")
+}
+
+func TestEmptyObjectWithWhitespace(t *testing.T) {
+	for _, in := range []string{"{ }", "{\n\t}", `[{ }, { }]`, `{"a": { }}`} {
+		out, err := Parse([]byte(in))
+		require.NoError(t, err, in)
+		assert.NotContains(t, string(out), " ", in)
+	}
+}
+
+// optionFixtures checks that the parsers returned by newParser accept the
+// y_ fixtures in dir, and reject the other ones with msg. defaultAccepts
+// tells whether a parser with default options accepts all of them, or rejects
+// all of them.
+func optionFixtures(t *testing.T, dir string, newParser func() *Parser, msg string, defaultAccepts bool) {
+	fixtures, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.NotEmpty(t, fixtures)
+	for _, f := range fixtures {
+		data, err := os.ReadFile(dir + "/" + f.Name())
+		require.NoError(t, err)
+		_, err = parseSingle(newParser(), data)
+		if strings.HasPrefix(f.Name(), "y_") {
+			assert.NoError(t, err, f.Name())
+		} else {
+			assert.ErrorContains(t, err, msg, f.Name())
+		}
+		_, err = Parse(data)
+		assert.Equal(t, defaultAccepts, err == nil, f.Name())
+	}
+}
+
+func TestCaseInsensitiveLiterals(t *testing.T) {
+	optionFixtures(t, "fixtures/case_insensitive_literals", func() *Parser {
+		return &Parser{CaseInsensitiveLiterals: true}
+	}, "(reading 'true')", false)
+
+	v, err := parseSingle(&Parser{CaseInsensitiveLiterals: true}, []byte(`[TRUE, Null, fALSE, "True"]`))
+	require.NoError(t, err)
+	assert.Equal(t, `[true,null,false,"True"]`, string(v))
+
+	p := &Parser{CaseInsensitiveLiterals: true}
+	_, err = feedAll(p, "NULL ")
+	require.NoError(t, err)
+	assert.Equal(t, Null, p.LastType())
+
+	// NaN keeps 'N' to itself.
+	p = &Parser{CaseInsensitiveLiterals: true, AllowNonFiniteNumbers: true}
+	v, err = feedAll(p, `[NaN, nULL, TRUE, Infinity]`)
+	require.NoError(t, err)
+	assert.Equal(t, `[NaN,null,true,Infinity]`, string(v))
+	_, err = feedAll(p, `NULL`)
+	assert.Error(t, err)
+	p = &Parser{CaseInsensitiveLiterals: true, AllowNonFiniteNumbers: true}
+	_, err = feedAll(p, `[nan]`)
+	assert.Error(t, err)
+}
+
+func TestRequireSortedKeys(t *testing.T) {
+	optionFixtures(t, "fixtures/sorted_keys", func() *Parser {
+		return &Parser{RequireSortedKeys: true}
+	}, "is not sorted after", true)
+}
+
+func TestMissingCommaBetweenMembers(t *testing.T) {
+	data, err := os.ReadFile("fixtures/n_object_missing_comma_between_members.json")
+	require.NoError(t, err)
+	cases := map[string]uint64{
+		string(data):                   7,
+		`{"a":1"b":2}`:                 6,
+		`{"a":"x" "b":2}`:              9,
+		`{"a":{} "b":2}`:               8,
+		`{"a":[1,2]` + "\n" + `"b":2}`: 11,
+		`{"a":true 1}`:                 10,
+	}
+	for in, offset := range cases {
+		_, err := Parse([]byte(in))
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, in)
+		assert.True(t, strings.HasPrefix(syntaxErr.Msg, "expected ',' or '}' between object members"), in)
+		assert.Equal(t, offset, syntaxErr.Offset, in)
+	}
+
+	_, err = Parse([]byte(`[1"a"]`))
+	assert.ErrorContains(t, err, "expected ','")
+	_, err = Parse([]byte(`1"a"`))
+	assert.Error(t, err)
+}
+
+func TestNDJSON(t *testing.T) {
+	records := func(data string) ([]string, error) {
+		p := &Parser{NDJSON: true}
+		var out []string
+		for _, b := range []byte(data) {
+			v, err := p.Feed(b)
+			if err != nil {
+				return out, err
+			}
+			if v != nil {
+				out = append(out, string(v))
+			}
+		}
+		return out, nil
+	}
+
+	out, err := records("{\"a\": \"line\\nbreak\"}\n\n[1, 2]\r\n3\n\"x\"\n")
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"a":"line\nbreak"}`, `[1,2]`, `3`, `"x"`}, out)
+
+	for _, in := range []string{"{\"a\":\n1}\n", "[1,\n2]\n", "\"a\nb\"\n", "{} {}\n", "1 2\n"} {
+		_, err := records(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestLineTerminated(t *testing.T) {
+	lines := func(data string) ([]string, error) {
+		p := &Parser{LineTerminated: true}
+		var out []string
+		for _, b := range []byte(data) {
+			v, err := p.Feed(b)
+			if err != nil {
+				return out, err
+			}
+			if v != nil {
+				out = append(out, string(v))
+			}
+		}
+		return out, nil
+	}
+
+	out, err := lines("42\n")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"42"}, out)
+
+	out, err = lines("true \r\n\n\"x\"\t\n-1.5e3\n{\n\"a\": [1,\n2]\n}\n")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"true", `"x"`, "-1.5e3", `{"a":[1,2]}`}, out)
+
+	// Values are held until their line ends.
+	out, err = lines("null")
+	require.NoError(t, err)
+	assert.Empty(t, out)
+
+	for _, in := range []string{"1 2\n", "{} {}\n", "\"a\"x\n", "null,\n"} {
+		_, err := lines(in)
+		assert.ErrorContains(t, err, "expected a newline after value", in)
+	}
+
+	d := NewDecoder(strings.NewReader("1\n[2]"))
+	d.p.LineTerminated = true
+	v, err := d.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(v))
+	v, err = d.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "[2]", string(v))
+	_, err = d.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestStatePositions(t *testing.T) {
+	first := map[parserState]string{
+		pTrue: "t", pFalse: "f", pNull: "n", pString: `"`, pObject: "{", pArray: "[",
+		pNumber: "-0123456789", pNaN: "N", pInfinity: "I", pNegInfinity: "-",
+	}
+	for _, in := range []string{
+		`0`, `1 `, `-1 `, `""`, `"a"`, `t`, `true`, `[]`, `{}`, `[0]`, `[1,2]`, `[""]`,
+		`{"":0}`, `{"a":1}`, `{ "a" : [ -0 , "" , {} ] , "b" :true}`, `[NaN,-Infinity,Infinity]`,
+	} {
+		for _, normalize := range []bool{false, true} {
+			p := &Parser{AllowNonFiniteNumbers: true, NormalizeWhitespace: normalize}
+			for i := 0; i < len(in); i++ {
+				_, err := p.Feed(in[i])
+				require.NoError(t, err, in)
+				for _, s := range p.stack {
+					if chars, ok := first[s.name]; ok {
+						require.Less(t, s.position, len(p.data), in)
+						assert.Contains(t, chars, string(p.data[s.position]), "%s: %s at %d", in, s.name, i)
+					}
+					if s.name == pObject && s.keyEnd > 0 {
+						assert.Equal(t, byte('"'), p.data[s.keyStart], in)
+					}
+				}
+			}
+		}
+	}
+
+	// Tokens of a single byte are complete as soon as they are known to
+	// end.
+	p := &Parser{}
+	v, err := feedAll(p, `[0,1,"",[],{}]`)
+	require.NoError(t, err)
+	assert.Equal(t, `[0,1,"",[],{}]`, string(v))
+	assert.Zero(t, p.MaxStringLenReached())
+}
+
+func TestMaxTotalNodes(t *testing.T) {
+	data, err := os.ReadFile("fixtures/limits/nodes_155.json")
+	require.NoError(t, err)
+	_, err = parseSingle(&Parser{MaxTotalNodes: 155}, data)
+	require.NoError(t, err)
+	_, err = parseSingle(&Parser{MaxTotalNodes: 154}, data)
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "MaxTotalNodes", limitErr.Limit)
+
+	p := &Parser{MaxTotalNodes: 3}
+	_, err = feedAll(p, `[1,2] [[3]] "a" {"a":{"b":1}}`)
+	require.NoError(t, err)
+	_, err = feedAll(p, `[1,[2]]`)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+
+	p = &Parser{MaxTotalNodes: 3, AllowElision: true}
+	_, err = feedAll(p, `[1,,2]`)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+
+	// Values spliced with FeedRaw count towards the value holding them, and
+	// not towards the next one.
+	p = &Parser{MaxTotalNodes: 3, MaxDistinctKeys: 1}
+	for i := 0; i < 3; i++ {
+		_, err = feedAll(p, `{"a":`)
+		require.NoError(t, err)
+		require.NoError(t, p.FeedRaw([]byte(`[1]`), Array), i)
+		_, err = feedAll(p, `}`)
+		require.NoError(t, err)
+		assert.Equal(t, 2, p.LastValueMaxDepth())
+	}
+	_, err = feedAll(p, `[`)
+	require.NoError(t, err)
+	require.NoError(t, p.FeedRaw([]byte(`1`), Number))
+	_, err = feedAll(p, `,`)
+	require.NoError(t, err)
+	require.NoError(t, p.FeedRaw([]byte(`2`), Number))
+	_, err = feedAll(p, `,`)
+	require.NoError(t, err)
+	assert.ErrorIs(t, p.FeedRaw([]byte(`3`), Number), ErrLimitExceeded)
+}
+
+func TestWhitespaceCallback(t *testing.T) {
+	type run struct {
+		ws      string
+		context WhitespaceContext
+	}
+	var runs []run
+	p := &Parser{}
+	p.SetWhitespaceCallback(func(ws []byte, context WhitespaceContext) {
+		runs = append(runs, run{string(ws), context})
+	})
+	_, err := feedAll(p, " {\n  \"a\" :  [ 1 ,\t2 ],\n  \"b\":{ }\n}\n\n3 \"x\"  ")
+	require.NoError(t, err)
+	assert.Equal(t, []run{
+		{" ", WhitespaceTopLevel},
+		{"\n  ", WhitespaceBeforeKey},
+		{" ", WhitespaceAfterKey},
+		{"  ", WhitespaceAfterColon},
+		{" ", WhitespaceBeforeElement},
+		{" ", WhitespaceAfterElement},
+		{"\t", WhitespaceBeforeElement},
+		{" ", WhitespaceAfterElement},
+		{"\n  ", WhitespaceBeforeKey},
+		{" ", WhitespaceBeforeKey},
+		{"\n", WhitespaceAfterValue},
+		{"\n\n", WhitespaceTopLevel},
+		{" ", WhitespaceTopLevel},
+	}, runs)
+}
+
+func TestForbidExponents(t *testing.T) {
+	optionFixtures(t, "fixtures/no_exponents", func() *Parser {
+		return &Parser{ForbidExponents: true}
+	}, "exponent notation not allowed", true)
+}
+
+func TestFeedOwned(t *testing.T) {
+	p := &Parser{}
+	var values [][]byte
+	for _, b := range []byte(`[1] {"a":2} "x" `) {
+		v, err := p.FeedOwned(b)
+		require.NoError(t, err)
+		if v != nil {
+			values = append(values, v)
+		}
+	}
+	require.Len(t, values, 3)
+	assert.Equal(t, "[1]", string(values[0]))
+	assert.Equal(t, `{"a":2}`, string(values[1]))
+	assert.Equal(t, `"x"`, string(values[2]))
+
+	_, err := p.FeedOwned('}')
+	assert.Error(t, err)
+}
+
+func TestSetMaxDepthAt(t *testing.T) {
+	newParser := func() *Parser {
+		p := &Parser{MaxDepth: 2}
+		p.SetMaxDepthAt("/tree", 5)
+		p.SetMaxDepthAt("/tree/leaf", 3)
+		p.SetMaxDepthAt("/free", 0)
+		return p
+	}
+
+	for _, in := range []string{
+		`{"tree": [[[[1]]]], "other": [1]}`,
+		`{"tree": {"leaf": [1], "x": [[[1]]]}}`,
+		`{"free": [[[[[[[[1]]]]]]]]}`,
+		`{"treetop": [1]}`,
+	} {
+		_, err := parseSingle(newParser(), []byte(in))
+		assert.NoError(t, err, in)
+	}
+
+	cases := map[string]string{
+		`{"other": [[1]]}`:               "",
+		`{"treetop": [[1]]}`:             "",
+		`{"tree": [[[[[1]]]]]}`:          "/tree",
+		`{"tree": {"leaf": [[1]]}}`:      "/tree/leaf",
+		`{"tree": {"leafy": [[[[1]]]]}}`: "/tree",
+	}
+	for in, path := range cases {
+		_, err := parseSingle(newParser(), []byte(in))
+		var limitErr *LimitError
+		require.ErrorAs(t, err, &limitErr, in)
+		assert.Equal(t, path, limitErr.Path, in)
+		if path != "" {
+			assert.Contains(t, err.Error(), fmt.Sprintf("at %q", path), in)
+		}
+	}
+
+	// Limits apply within values spliced by FeedRaw as well.
+	p := newParser()
+	_, err := feedAll(p, `{"tree": `)
+	require.NoError(t, err)
+	require.NoError(t, p.FeedRaw([]byte(`{"x": [[[1]]], "leaf": [1]}`), Object))
+
+	p = newParser()
+	_, err = feedAll(p, `{"tree": `)
+	require.NoError(t, err)
+	err = p.FeedRaw([]byte(`{"x": [[1]], "le\u0061f": [[1]]}`), Object)
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "/tree/leaf", limitErr.Path)
+	assert.Equal(t, uint64(36), limitErr.Offset)
+}
+
+func TestEscapedBackslashes(t *testing.T) {
+	cases := map[string]string{
+		`"\\"`:         `"\\"`,
+		`["\\", 1]`:    `["\\",1]`,
+		`"a\\\"b"`:     `"a\\\"b"`,
+		`{"a\\":"\\"}`: `{"a\\":"\\"}`,
+	}
+	for in, expected := range cases {
+		out, err := Parse([]byte(in))
+		require.NoError(t, err, in)
+		assert.Equal(t, expected, string(out), in)
+	}
+
+	for _, in := range []string{`{"a\\":1":2}`, `["\\"x"]`, `"\\\"`} {
+		_, err := Parse([]byte(in))
+		assert.Error(t, err, in)
+		_, err = Unmarshal([]byte(in))
+		assert.Error(t, err, in)
+	}
+}
+
+func TestAllowUnescapedNewlinesInStrings(t *testing.T) {
+	optionFixtures(t, "fixtures/unescaped_newlines", func() *Parser {
+		return &Parser{AllowUnescapedNewlinesInStrings: true}
+	}, "unescaped control character", false)
+}
+
+func TestUnescapedControlCharacters(t *testing.T) {
+	for b := byte(0); b < 0x20; b++ {
+		_, err := Parse([]byte{'"', 'a', b, '"'})
+		assert.ErrorContains(t, err, "unescaped control character", "%#02x", b)
+	}
+	out, err := Parse([]byte("\"\\n\\t\x7f\""))
+	require.NoError(t, err)
+	assert.Equal(t, "\"\\n\\t\x7f\"", string(out))
+}
+
+func TestSkipBOM(t *testing.T) {
+	const bom = "\xEF\xBB\xBF"
+
+	values := func(p *Parser, data string) ([]string, error) {
+		var out []string
+		for _, b := range []byte(data) {
+			v, err := p.Feed(b)
+			if err != nil {
+				return out, err
+			}
+			if v != nil {
+				out = append(out, string(v))
+			}
+		}
+		return out, nil
+	}
+
+	out, err := values(&Parser{SkipBOM: true}, bom+`{"a":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"a":1}`}, out)
+
+	_, err = values(&Parser{}, bom+`{}`)
+	assert.Error(t, err)
+	_, err = values(&Parser{SkipBOM: true}, `{}`+bom+`{}`)
+	assert.Error(t, err)
+	_, err = values(&Parser{SkipBOM: true}, " "+bom+`{}`)
+	assert.Error(t, err)
+
+	out, err = values(&Parser{SkipBOMPerValue: true}, bom+`{"a":1}`+bom+`{"b":2}`+"\n"+bom+`[3]`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"a":1}`, `{"b":2}`, `[3]`}, out)
+
+	for _, in := range []string{
+		`[` + bom + `1]`,
+		`{"a":` + bom + `1}`,
+		bom + bom + `{}`,
+		"\xEF\xBB{}",
+	} {
+		_, err := values(&Parser{SkipBOMPerValue: true}, in)
+		assert.Error(t, err, "%q", in)
+	}
+
+	out, err = values(&Parser{SkipBOMPerValue: true}, `["`+bom+`"]`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`["` + bom + `"]`}, out, "a BOM within a string is its content")
+}
+
+func TestRawNumberCallback(t *testing.T) {
+	var seen []string
+	p := &Parser{}
+	p.SetRawNumberCallback(func(raw []byte) ([]byte, error) {
+		seen = append(seen, string(raw))
+		if raw[0] == '-' {
+			return nil, errors.New("negative prices are not allowed")
+		}
+		if bytes.HasSuffix(raw, []byte("0")) && bytes.IndexByte(raw, '.') >= 0 {
+			return bytes.TrimRight(raw, "0"), nil
+		}
+		return nil, nil
+	})
+
+	out, err := feedAll(p, `{"price": 1.50, "qty": [10, 2.0e1]}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"price":1.5,"qty":[10,2.0e1]}`, string(out))
+	assert.Equal(t, []string{"1.50", "10", "2.0e1"}, seen)
+
+	v, err := parseSingle(p, []byte("3.250"))
+	require.NoError(t, err)
+	assert.Equal(t, "3.25", string(v))
+
+	p.Reset()
+	_, err = feedAll(p, `{"price": -1}`)
+	assert.EqualError(t, err, "negative prices are not allowed")
+	p.Reset()
+	_, err = parseSingle(p, []byte("-1"))
+	assert.EqualError(t, err, "negative prices are not allowed")
+}
+
+func TestMaxKeyLen(t *testing.T) {
+	longKey, err := os.ReadFile("fixtures/limits/long_key.json")
+	require.NoError(t, err)
+	longValue, err := os.ReadFile("fixtures/limits/long_value.json")
+	require.NoError(t, err)
+
+	tightKeys := Parser{MaxKeyLen: 16, MaxStringLen: 1024}
+	p := tightKeys
+	_, err = parseSingle(&p, longValue)
+	require.NoError(t, err)
+	p = tightKeys
+	_, err = parseSingle(&p, longKey)
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "MaxKeyLen", limitErr.Limit)
+
+	tightValues := Parser{MaxKeyLen: 1024, MaxStringLen: 16}
+	p = tightValues
+	_, err = parseSingle(&p, longKey)
+	require.NoError(t, err)
+	p = tightValues
+	_, err = parseSingle(&p, longValue)
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "MaxStringLen", limitErr.Limit)
+}
+
+func TestFeedBytesFunc(t *testing.T) {
+	data := []byte(`{"id": 1} {"id": 2, "match": true} 3 [4]`)
+	p := &Parser{}
+	var seen []string
+	consumed, err := p.FeedBytesFunc(data, func(value []byte) (bool, error) {
+		seen = append(seen, string(value))
+		return bytes.Contains(value, []byte(`"match"`)), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"id":1}`, `{"id":2,"match":true}`}, seen)
+	assert.Equal(t, len(`{"id": 1} {"id": 2, "match": true}`), consumed)
+
+	seen = nil
+	n, err := p.FeedBytesFunc(data[consumed:], func(value []byte) (bool, error) {
+		seen = append(seen, string(value))
+		return false, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, len(data)-consumed, n)
+	assert.Equal(t, []string{"3", "[4]"}, seen)
+
+	p.Reset()
+	errStop := errors.New("stop")
+	consumed, err = p.FeedBytesFunc([]byte(`1 2 3`), func(value []byte) (bool, error) {
+		return false, errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, 2, consumed)
+
+	p.Reset()
+	consumed, err = p.FeedBytesFunc([]byte(`[1] [2,]`), func([]byte) (bool, error) { return false, nil })
+	assert.Error(t, err)
+	assert.Equal(t, 7, consumed)
+}
+
+func TestNumbersAsStrings(t *testing.T) {
+	p := &Parser{NumbersAsStrings: true}
+	out, err := parseSingle(p, []byte(`[1e10, 9007199254740993]`))
+	require.NoError(t, err)
+	assert.Equal(t, `["1e10","9007199254740993"]`, string(out))
+
+	p.Reset()
+	out, err = feedAll(p, `{"id": -0.5, "n": [1, {"x": 2}], "s": "3"} 42 `)
+	require.NoError(t, err)
+	assert.Equal(t, `"42"`, string(out))
+	assert.Equal(t, Number, p.LastType())
+
+	p.Reset()
+	out, err = feedAll(p, `{"id": -0.5, "n": [1, {"x": 2}], "s": "3"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"-0.5","n":["1",{"x":"2"}],"s":"3"}`, string(out))
+
+	p = &Parser{NumbersAsStrings: true, AllowNonFiniteNumbers: true}
+	out, err = parseSingle(p, []byte(`[NaN, -Infinity, 7]`))
+	require.NoError(t, err)
+	assert.Equal(t, `["NaN","-Infinity","7"]`, string(out))
+
+	_, err = parseSingle(&Parser{NumbersAsStrings: true}, []byte(`[01]`))
+	assert.Error(t, err)
+}
+
+func TestMemoryFootprint(t *testing.T) {
+	p := &Parser{RejectDuplicateKeys: true}
+	assert.Zero(t, p.MemoryFootprint())
+
+	_, err := feedAll(p, `[1, 2] `)
+	require.NoError(t, err)
+	small := p.MemoryFootprint()
+	assert.Positive(t, small)
+
+	_, err = feedAll(p, `{"a": [[[[{"`+strings.Repeat("k", 1000)+`": 1, "b": "`+strings.Repeat("v", 4000))
+	require.NoError(t, err)
+	large := p.MemoryFootprint()
+	assert.Greater(t, large, small+5000)
+
+	p.Reset()
+	assert.Equal(t, large, p.MemoryFootprint(), "buffers are kept across resets")
+	assert.Zero(t, (&Parser{}).MemoryFootprint())
+}
+
+func TestMemoryFootprintConfiguration(t *testing.T) {
+	p := &Parser{}
+	last := 0
+	grows := func(what string) {
+		n := p.MemoryFootprint()
+		assert.Greater(t, n, last, what)
+		last = n
+	}
+	p.ValidateAt("/"+strings.Repeat("a", 100), func([]byte) error { return nil })
+	grows("validators")
+	p.SetMaxDepthAt("/"+strings.Repeat("b", 100), 3)
+	grows("depth limits")
+	p.RegisterEscape('v', 0, nil)
+	grows("escapes")
+	p.SetSchema(NewSchema().Add("/"+strings.Repeat("c", 100), Rule{Required: []string{"x"}}))
+	grows("schema")
+	p.MaxDistinctKeys = 10
+	_, err := feedAll(p, `{"a": 1}`)
+	require.NoError(t, err)
+	assert.Greater(t, p.MemoryFootprint(), last+distinctRegisters, "distinct key estimator")
+}
+
+func TestFeedEmptyStack(t *testing.T) {
+	type result struct {
+		at    int
+		value string
+	}
+	feed := func(in string) ([]result, error) {
+		p := &Parser{}
+		var out []result
+		for i, b := range []byte(in) {
+			v, err := p.Feed(b)
+			if err != nil {
+				return out, err
+			}
+			if v != nil {
+				out = append(out, result{i, string(v)})
+			}
+		}
+		return out, nil
+	}
+
+	tests := map[string][]result{
+		"false":          {{4, "false"}},
+		" \t\r\n false":  {{9, "false"}},
+		"false \n ":      {{4, "false"}},
+		"falsetrue":      {{4, "false"}, {8, "true"}},
+		"null\tnull ":    {{3, "null"}, {8, "null"}},
+		`"x""y"`:         {{2, `"x"`}, {5, `"y"`}},
+		"{}[]":           {{1, "{}"}, {3, "[]"}},
+		"12 ":            {{2, "12"}},
+		"1\n\n2\n":       {{1, "1"}, {4, "2"}},
+		"[1]  2 ":        {{2, "[1]"}, {6, "2"}},
+		"   ":            nil,
+		"12":             nil,
+		`{"a": [1, {}]}`: {{13, `{"a":[1,{}]}`}},
+	}
+	for in, want := range tests {
+		out, err := feed(in)
+		require.NoError(t, err, "%q", in)
+		assert.Equal(t, want, out, "%q", in)
+	}
+
+	for in, at := range map[string]int{
+		"false1x": 6,
+		"1false":  1,
+		"1,":      1,
+		"1}":      1,
+		"[1]]":    3,
+		"true ]":  5,
+		"]":       0,
+	} {
+		_, err := feed(in)
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, "%q", in)
+		assert.Equal(t, uint64(at), syntaxErr.Offset, "%q", in)
+	}
+}
+
+func TestAllowDigitSeparators(t *testing.T) {
+	optionFixtures(t, "fixtures/digit_separators", func() *Parser {
+		return &Parser{AllowDigitSeparators: true}
+	}, "digit separator", false)
+	for in, msg := range map[string]string{
+		"[0_1]": "leading zeros are not allowed at position 2",
+		"-0_1":  "leading zeros are not allowed at position 2",
+		"1_":    "unexpected end of input at position 2",
+		"[_1]":  "unexpected '_', digit separator must follow a digit at position 1",
+		"[1__]": "unexpected '_', digit separator '_' must be followed by a digit at position 3",
+	} {
+		_, err := parseSingle(&Parser{AllowDigitSeparators: true}, []byte(in))
+		assert.ErrorContains(t, err, msg, in)
+	}
+	_, err := parseSingle(&Parser{}, []byte("[1_0]"))
+	assert.ErrorContains(t, err, "unexpected '_' at position 2")
+
+	d := NewDecoder(strings.NewReader(`[1_000, 2.5_0] 3_0`))
+	d.Parser().AllowDigitSeparators = true
+	v, err := d.DecodeNext()
+	require.NoError(t, err)
+	assert.Equal(t, []any{1000.0, 2.5}, v)
+
+	out, err := parseSingle(&Parser{AllowDigitSeparators: true}, []byte("1_000_000"))
+	require.NoError(t, err)
+	assert.Equal(t, "1_000_000", string(out))
+	f, err := ParseNumber(out)
+	require.NoError(t, err)
+	assert.Equal(t, 1e6, f)
 }
@@ -0,0 +1,89 @@
+package sjson
+
+import (
+	"strconv"
+	"strings"
+)
+
+// PathSegment is a single step in the path to a value: either a key of an
+// object member, or the index of an array element.
+type PathSegment struct {
+	// Key is the unescaped key of an object member. Only meaningful when
+	// IsIndex returns false.
+	Key string
+	// Index is the position of an array element, or -1 for object members.
+	Index int
+}
+
+// IsIndex returns whether the segment addresses an array element.
+func (s PathSegment) IsIndex() bool {
+	return s.Index >= 0
+}
+
+func (s PathSegment) String() string {
+	if s.IsIndex() {
+		return strconv.Itoa(s.Index)
+	}
+	return s.Key
+}
+
+// PathSegments returns the location of the value currently being parsed,
+// from the outermost container inwards. Containers in which no element or
+// member was reached yet (for instance, while an object key is still being
+// read) contribute no segment.
+func (p *Parser) PathSegments() []PathSegment {
+	var segments []PathSegment
+	for _, s := range p.stack {
+		switch s.name {
+		case pArray:
+			if s.index > 0 {
+				segments = append(segments, PathSegment{Index: s.index - 1})
+			}
+		case pObject:
+			if s.keyEnd > 0 {
+				segments = append(segments, PathSegment{Key: p.decodeKey(s), Index: -1})
+			}
+		}
+	}
+	return segments
+}
+
+// Path returns the location of the value currently being parsed as a JSON
+// Pointer (RFC 6901). The top-level value is represented by an empty string.
+func (p *Parser) Path() string {
+	return pointerOf(p.PathSegments())
+}
+
+// pointerOf returns the JSON Pointer made of segments.
+func pointerOf(segments []PathSegment) string {
+	var sb strings.Builder
+	for _, s := range segments {
+		sb.WriteByte('/')
+		sb.WriteString(escapePointer(s.String()))
+	}
+	return sb.String()
+}
+
+func (p *Parser) decodeKey(s state) string {
+	raw := p.data[s.keyStart:s.keyEnd]
+	key, err := p.Unescape(raw)
+	if err != nil {
+		return string(raw[1 : len(raw)-1])
+	}
+	return key
+}
+
+// pointerContains returns whether the value at path lies within the one at
+// pointer, or is that value itself.
+func pointerContains(pointer, path string) bool {
+	return path == pointer || strings.HasPrefix(path, pointer+"/")
+}
+
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+func escapePointer(s string) string {
+	return pointerEscaper.Replace(s)
+}
@@ -0,0 +1,50 @@
+package sjson
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pathAt feeds data into a new parser and returns its path once the byte at
+// index i is fed.
+func pathAt(t *testing.T, data string, i int) (string, []PathSegment) {
+	p := &Parser{}
+	for _, b := range []byte(data[:i+1]) {
+		_, err := p.Feed(b)
+		require.NoError(t, err)
+	}
+	return p.Path(), p.PathSegments()
+}
+
+func TestPath(t *testing.T) {
+	data := `{"a": [1, {"b/c": true, "d~": [null, "x"]}], "e\u0041": 2}`
+	tests := map[string]string{
+		"1":    "/a/0",
+		"true": "/a/1/b~1c",
+		"null": "/a/1/d~0/0",
+		`"x"`:  "/a/1/d~0/1",
+		"2":    "/eA",
+	}
+	for token, expected := range tests {
+		t.Run(token, func(t *testing.T) {
+			i := strings.Index(data, token) + len(token) - 1
+			path, _ := pathAt(t, data, i)
+			assert.Equal(t, expected, path)
+		})
+	}
+
+	path, segments := pathAt(t, data, 0)
+	assert.Equal(t, "", path)
+	assert.Empty(t, segments)
+}
+
+func TestPathSegments(t *testing.T) {
+	data := `[[], {"k": [0, 1, 2]}]`
+	_, segments := pathAt(t, data, strings.Index(data, "2"))
+	assert.Equal(t, []PathSegment{{Index: 1}, {Key: "k", Index: -1}, {Index: 2}}, segments)
+	assert.True(t, segments[0].IsIndex())
+	assert.False(t, segments[1].IsIndex())
+}
@@ -0,0 +1,122 @@
+package sjson
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// queryStep is a single step of a query: either a PathSegment to match
+// exactly, or a wildcard.
+type queryStep struct {
+	segment  PathSegment
+	wildcard bool
+}
+
+func (q queryStep) matches(s PathSegment) bool {
+	if q.wildcard {
+		return true
+	}
+	return q.segment == s
+}
+
+// QueryStream reads the single JSON value in r and calls fn with the bytes of
+// every value matched by expr, in one pass. Values are reported as soon as
+// they are complete, so a match nested within another is reported first. The
+// slice passed to fn must be copied if retained.
+//
+// expr is a small subset of JSONPath: it starts with $, denoting the
+// top-level value, followed by any number of steps descending one level
+// each:
+//
+//	.name      the member of an object with the given key
+//	['name']   the same, for keys holding '.', '[' or ']'; ["name"] also works
+//	[n]        the element of an array at index n
+//	.* or [*]  every member of an object or element of an array
+//
+// Keys are compared after escape sequences are resolved, and can't hold the
+// quote delimiting them. Recursive descent (..), filters, slices and unions
+// are not supported. For example, $.items[*].id matches the id of every
+// element of the items array.
+func QueryStream(r io.Reader, expr string, fn func(match []byte)) error {
+	steps, err := parseQuery(expr)
+	if err != nil {
+		return err
+	}
+	p := &Parser{}
+	p.completedFn = func(value []byte) {
+		segments := p.PathSegments()
+		if len(segments) != len(steps) {
+			return
+		}
+		for i, s := range segments {
+			if !steps[i].matches(s) {
+				return
+			}
+		}
+		fn(value)
+	}
+	s := singleValue{p: p}
+	_, err = s.readFrom(r)
+	return err
+}
+
+func parseQuery(expr string) ([]queryStep, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("invalid query %q: must start with $", expr)
+	}
+	var steps []queryStep
+	rest := expr[1:]
+	for rest != "" {
+		var step queryStep
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("invalid query %q: empty key", expr)
+			}
+			if strings.IndexByte(name, ']') >= 0 {
+				return nil, fmt.Errorf("invalid query %q: unexpected ']'", expr)
+			}
+			if name == "*" {
+				step.wildcard = true
+			} else {
+				step.segment = PathSegment{Key: name, Index: -1}
+			}
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if rest[1:] != "" && (rest[1] == '\'' || rest[1] == '"') {
+				end = strings.IndexByte(rest[2:], rest[1]) + 2
+				if end < 2 || end+1 >= len(rest) || rest[end+1] != ']' {
+					return nil, fmt.Errorf("invalid query %q: unterminated key", expr)
+				}
+				step.segment = PathSegment{Key: rest[2:end], Index: -1}
+				rest = rest[end+2:]
+				break
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("invalid query %q: missing ']'", expr)
+			}
+			if inner := rest[1:end]; inner == "*" {
+				step.wildcard = true
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 || inner[0] == '+' {
+					return nil, fmt.Errorf("invalid query %q: invalid index %q", expr, inner)
+				}
+				step.segment = PathSegment{Index: n}
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid query %q: unexpected %q", expr, rest[0])
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
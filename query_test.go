@@ -0,0 +1,57 @@
+package sjson
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryStream(t *testing.T) {
+	doc := `{"items": [{"id": 1, "tags": ["a"]}, {"name": "x"}, {"id": {"n": 2}}],
+		"meta": {"id": 3, "a.b": [true, false]}, "items": [{"id": 4}]}`
+
+	query := func(expr string) []string {
+		var matches []string
+		err := QueryStream(iotest.HalfReader(strings.NewReader(doc)), expr, func(match []byte) {
+			matches = append(matches, string(match))
+		})
+		require.NoError(t, err, expr)
+		return matches
+	}
+
+	assert.Equal(t, []string{`1`, `{"n":2}`, `4`}, query(`$.items[*].id`))
+	assert.Equal(t, []string{`1`, `{"n":2}`, `4`}, query(`$.items.*.id`))
+	assert.Equal(t, []string{`{"name":"x"}`}, query(`$.items[1]`))
+	assert.Equal(t, []string{`false`}, query(`$.meta['a.b'][1]`))
+	assert.Equal(t, []string{`true`, `false`}, query(`$["meta"]["a.b"][*]`))
+	assert.Equal(t, []string{`3`, `[true,false]`}, query(`$.meta.*`))
+	assert.Equal(t, []string{`1`, `{"n":2}`, `4`}, query(`$.*.*.id`))
+	assert.Equal(t, []string{`3`}, query(`$.*.id`))
+	assert.Equal(t, []string{`"a"`}, query(`$.*[0].tags[0]`))
+	assert.Empty(t, query(`$.items[7]`))
+	assert.Empty(t, query(`$.missing[*]`))
+	assert.Len(t, query(`$`), 1)
+
+	err := QueryStream(strings.NewReader(`{"a": [1,`), "$.a[*]", func([]byte) {})
+	assert.Error(t, err)
+}
+
+func TestParseQuery(t *testing.T) {
+	steps, err := parseQuery(`$.a[*][2]['b]c'].*`)
+	require.NoError(t, err)
+	assert.Equal(t, []queryStep{
+		{segment: PathSegment{Key: "a", Index: -1}},
+		{wildcard: true},
+		{segment: PathSegment{Index: 2}},
+		{segment: PathSegment{Key: "b]c", Index: -1}},
+		{wildcard: true},
+	}, steps)
+
+	for _, expr := range []string{"", "a", "$..a", "$.", "$[", "$[x]", "$[-1]", "$[+1]", "$['a]", "$['a'", "$a", "$.a]"} {
+		_, err := parseQuery(expr)
+		assert.Error(t, err, expr)
+	}
+}
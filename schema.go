@@ -0,0 +1,232 @@
+package sjson
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// Schema describes the values expected at given locations of a document, for
+// validation while it is streamed with SetSchema. It covers a small subset of
+// JSON Schema: the accepted types of a value, the keys an object must hold,
+// bounds for numbers and lengths for strings. Every Rule whose pointer
+// matches a value applies to it.
+type Schema struct {
+	rules []schemaRule
+}
+
+// Rule constrains the values at a location of a Schema. Zero fields impose no
+// constraint.
+type Rule struct {
+	// Types lists the accepted types. Empty accepts any type.
+	Types []ValueType
+	// Required lists the keys an object must hold. It is ignored for other
+	// types.
+	Required []string
+	// Min and Max bound numbers, inclusively. Nil means unbounded.
+	Min, Max *float64
+	// MinLength and MaxLength bound the length of strings, counted in
+	// characters after escape sequences are resolved. Zero MaxLength means
+	// unbounded.
+	MinLength, MaxLength int
+}
+
+type schemaRule struct {
+	pointer  string
+	segments []string
+	rule     Rule
+}
+
+// footprint estimates the bytes held by the schema, for
+// Parser.MemoryFootprint.
+func (s *Schema) footprint() int {
+	n := cap(s.rules) * int(unsafe.Sizeof(schemaRule{}))
+	for _, r := range s.rules {
+		n += len(r.pointer) + cap(r.segments)*int(unsafe.Sizeof(""))
+		for _, seg := range r.segments {
+			n += len(seg)
+		}
+		n += cap(r.rule.Types) * int(unsafe.Sizeof(ValueType(0)))
+		n += cap(r.rule.Required) * int(unsafe.Sizeof(""))
+		for _, k := range r.rule.Required {
+			n += len(k)
+		}
+	}
+	return n
+}
+
+// NewSchema returns an empty Schema.
+func NewSchema() *Schema {
+	return &Schema{}
+}
+
+// Add registers rule for the values at pointer, a JSON Pointer in which a
+// segment made of a single "*" matches any object member or array element.
+// It returns s, so calls can be chained.
+func (s *Schema) Add(pointer string, rule Rule) *Schema {
+	var segments []string
+	if pointer != "" {
+		segments = strings.Split(pointer[1:], "/")
+		for i, seg := range segments {
+			segments[i] = pointerUnescaper.Replace(seg)
+		}
+	}
+	s.rules = append(s.rules, schemaRule{pointer: pointer, segments: segments, rule: rule})
+	return s
+}
+
+func (r schemaRule) matches(path []PathSegment) bool {
+	if len(path) != len(r.segments) {
+		return false
+	}
+	for i, seg := range r.segments {
+		if seg != "*" && seg != path[i].String() {
+			return false
+		}
+	}
+	return true
+}
+
+// SetSchema makes the parser validate values against s while parsing them.
+// Types are checked as soon as a value begins, and other constraints once it
+// is complete, so violations are reported as early as they can be found, as
+// ValidationErrors. Passing nil disables validation.
+func (p *Parser) SetSchema(s *Schema) {
+	p.schema = s
+}
+
+// schemaRules returns the rules applying to the value being parsed.
+func (p *Parser) schemaRules() []Rule {
+	var rules []Rule
+	path := p.PathSegments()
+	for _, r := range p.schema.rules {
+		if r.matches(path) {
+			rules = append(rules, r.rule)
+		}
+	}
+	return rules
+}
+
+func (p *Parser) schemaError(msg string, args ...any) error {
+	return &ValidationError{Path: p.Path(), Offset: p.offset - 1, Err: fmt.Errorf(msg, args...)}
+}
+
+// checkSchemaStart validates the type of the value beginning with b.
+func (p *Parser) checkSchemaStart(b byte) error {
+	t, ok := p.valueTypeOf(b)
+	if !ok {
+		// Not a value, which is left for the parser to reject.
+		return nil
+	}
+	return p.checkSchemaType(t)
+}
+
+// checkSchemaType validates the type of a value as it begins.
+func (p *Parser) checkSchemaType(t ValueType) error {
+	for _, r := range p.schemaRules() {
+		if len(r.Types) > 0 && !hasType(r.Types, t) {
+			return p.schemaError("expected %s, found %s", typeList(r.Types), t)
+		}
+	}
+	return nil
+}
+
+func hasType(types []ValueType, t ValueType) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+func typeList(types []ValueType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.String()
+	}
+	return strings.Join(names, " or ")
+}
+
+// checkSchemaValue validates a completed value, held in data.
+func (p *Parser) checkSchemaValue(s state, data []byte) error {
+	rules := p.schemaRules()
+	if len(rules) == 0 {
+		return nil
+	}
+	switch s.name {
+	case pObject:
+		keys := memberKeys(data)
+		for _, r := range rules {
+			for _, k := range r.Required {
+				if !keys[k] {
+					return p.schemaError("missing required key %q", k)
+				}
+			}
+		}
+	case pNumber:
+		n, err := strconv.ParseFloat(strings.Trim(string(data), `"`), 64)
+		if err != nil {
+			return nil
+		}
+		for _, r := range rules {
+			if r.Min != nil && n < *r.Min {
+				return p.schemaError("%s is less than the minimum of %v", data, *r.Min)
+			}
+			if r.Max != nil && n > *r.Max {
+				return p.schemaError("%s is greater than the maximum of %v", data, *r.Max)
+			}
+		}
+	case pString:
+		n := len(data) - 2
+		if str, err := UnescapeString(data); err == nil {
+			n = utf8.RuneCountInString(str)
+		}
+		for _, r := range rules {
+			if n < r.MinLength {
+				return p.schemaError("string is shorter than %d characters", r.MinLength)
+			}
+			if r.MaxLength > 0 && n > r.MaxLength {
+				return p.schemaError("string is longer than %d characters", r.MaxLength)
+			}
+		}
+	}
+	return nil
+}
+
+// memberKeys returns the unescaped keys of the members of the object held in
+// data, as validated by the parser.
+func memberKeys(data []byte) map[string]bool {
+	keys := map[string]bool{}
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case leftCurly, leftSquared:
+			depth++
+		case rightCurly, rightSquared:
+			depth--
+		case quote:
+			start := i
+			for i++; data[i] != quote; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+			if depth != 1 {
+				continue
+			}
+			j := i + 1
+			for j < len(data) && isWsp(data[j]) {
+				j++
+			}
+			if j < len(data) && data[j] == ':' {
+				if key, err := UnescapeString(data[start : i+1]); err == nil {
+					keys[key] = true
+				}
+			}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,78 @@
+package sjson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	min, max := 0.0, 100.0
+	schema := NewSchema().
+		Add("", Rule{Types: []ValueType{Object}, Required: []string{"id", "items"}}).
+		Add("/id", Rule{Types: []ValueType{Number, String}}).
+		Add("/items", Rule{Types: []ValueType{Array}}).
+		Add("/items/*", Rule{Types: []ValueType{Object}, Required: []string{"price"}}).
+		Add("/items/*/price", Rule{Types: []ValueType{Number}, Min: &min, Max: &max}).
+		Add("/items/*/name", Rule{MinLength: 1, MaxLength: 4}).
+		Add("/a~1b", Rule{Types: []ValueType{Null}})
+
+	parse := func(in string) error {
+		p := &Parser{}
+		p.SetSchema(schema)
+		_, err := parseSingle(p, []byte(in))
+		return err
+	}
+
+	require.NoError(t, parse(`{"id": "x", "items": [{"price": 0, "name": "été"}, {"price": 1e2, "x": [1]}], "a/b": null}`))
+	require.NoError(t, parse(`{"items": [], "id": 12, "other": {"id": true}}`))
+
+	tests := []struct {
+		in     string
+		path   string
+		offset uint64
+		msg    string
+	}{
+		{`[]`, "", 0, "expected object, found array"},
+		{`{"id": true, "items": []}`, "/id", 7, "expected number or string, found bool"},
+		{`{"id": 1, "items": [1]}`, "/items/0", 20, "expected object, found number"},
+		{`{"id": 1, "items": [{"price": -1}]}`, "/items/0/price", 32, "-1 is less than the minimum of 0"},
+		{`{"id": 1, "items": [{"price": 101}]}`, "/items/0/price", 33, "101 is greater than the maximum of 100"},
+		{`{"id": 1, "items": [{"price": 1, "name": ""}]}`, "/items/0/name", 42, "string is shorter than 1 characters"},
+		{`{"id": 1, "items": [{"price": 1, "name": "abcde"}]}`, "/items/0/name", 47, "string is longer than 4 characters"},
+		{`{"id": 1, "items": [{"name": "a", "p": {"price": 1}}]}`, "/items/0", 51, `missing required key "price"`},
+		{`{"items": [], "x": {"id": 1}}`, "", 28, `missing required key "id"`},
+		{`{"id": 1, "items": [], "a/b": 0}`, "/a~1b", 30, "expected null, found number"},
+	}
+	for _, tt := range tests {
+		err := parse(tt.in)
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr, tt.in)
+		assert.Equal(t, tt.path, validationErr.Path, tt.in)
+		assert.Equal(t, tt.offset, validationErr.Offset, tt.in)
+		assert.EqualError(t, validationErr.Err, tt.msg, tt.in)
+	}
+}
+
+func TestSchemaFeedRaw(t *testing.T) {
+	schema := NewSchema().Add("/0", Rule{Types: []ValueType{String}, MaxLength: 2})
+	for raw, kind := range map[string]ValueType{`12`: Number, `"abc"`: String, `"ab"`: String} {
+		p := &Parser{}
+		p.SetSchema(schema)
+		_, err := feedAll(p, "[")
+		require.NoError(t, err)
+		err = p.FeedRaw([]byte(raw), kind)
+		if raw == `"ab"` {
+			assert.NoError(t, err)
+		} else {
+			var validationErr *ValidationError
+			assert.ErrorAs(t, err, &validationErr, raw)
+		}
+	}
+}
+
+func TestMemberKeys(t *testing.T) {
+	keys := memberKeys([]byte(`{"a": {"b": 1}, "c\"": ["d", {"e": 2}], "f": "g:"}`))
+	assert.Equal(t, map[string]bool{"a": true, `c"`: true, "f": true}, keys)
+}
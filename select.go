@@ -0,0 +1,29 @@
+package sjson
+
+import (
+	"io"
+)
+
+// SelectMultiple reads the single JSON value in r and returns the bytes of
+// the values found at each of the given JSON Pointers, in one pass. Every
+// pointer is a key of the returned map; pointers not found in the document
+// map to nil. When an object holds a key more than once, the last value
+// wins.
+func SelectMultiple(r io.Reader, pointers []string) (map[string][]byte, error) {
+	found := make(map[string][]byte, len(pointers))
+	p := &Parser{}
+	for _, pointer := range pointers {
+		pointer := pointer
+		found[pointer] = nil
+		p.ValidateAt(pointer, func(value []byte) error {
+			found[pointer] = append([]byte(nil), value...)
+			return nil
+		})
+	}
+
+	s := singleValue{p: p}
+	if _, err := s.readFrom(r); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
@@ -0,0 +1,38 @@
+package sjson
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectMultiple(t *testing.T) {
+	doc := `{"a": {"b": [10, {"c": "x"}], "d/e": true}, "f": 1.5, "a~b": null, "g": 1, "g": 2}`
+	found, err := SelectMultiple(iotest.HalfReader(strings.NewReader(doc)), []string{
+		"/a/b/1/c", "/a/b/0", "/a/d~1e", "/f", "/a~0b", "/a/b", "/g", "/missing", "/a/b/5",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"/a/b/1/c": []byte(`"x"`),
+		"/a/b/0":   []byte(`10`),
+		"/a/d~1e":  []byte(`true`),
+		"/f":       []byte(`1.5`),
+		"/a~0b":    []byte(`null`),
+		"/a/b":     []byte(`[10,{"c":"x"}]`),
+		"/g":       []byte(`2`),
+		"/missing": nil,
+		"/a/b/5":   nil,
+	}, found)
+
+	found, err = SelectMultiple(strings.NewReader(" 42 "), []string{""})
+	require.NoError(t, err)
+	assert.Equal(t, "42", string(found[""]))
+
+	_, err = SelectMultiple(strings.NewReader(`{"a": 1`), []string{"/a"})
+	assert.Error(t, err)
+	_, err = SelectMultiple(strings.NewReader(`{} {}`), []string{"/a"})
+	assert.Error(t, err)
+}
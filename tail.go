@@ -0,0 +1,34 @@
+package sjson
+
+import (
+	"io"
+	"time"
+)
+
+// TailDecoder reads values from a stream that keeps growing, such as a log
+// file being appended to. Once its reader returns io.EOF, it waits and reads
+// again instead of reporting the end of the stream, so Next blocks until the
+// next value is complete. A value left partial at the end of the available
+// data is held by the parser, and completed once more bytes arrive. As the
+// stream never ends, a top-level number is only returned once the byte
+// following it is written, as with NDJSON, where each value ends with a
+// newline. Use NextContext to stop waiting.
+type TailDecoder struct {
+	Decoder
+}
+
+// defaultTailPoll is how long a TailDecoder waits between reads when no
+// positive interval is given.
+const defaultTailPoll = 100 * time.Millisecond
+
+// NewTailDecoder returns a TailDecoder reading values from r, and waiting
+// for poll before each new read once r is exhausted. A poll of zero or less
+// waits 100ms.
+func NewTailDecoder(r io.Reader, poll time.Duration) *TailDecoder {
+	if poll <= 0 {
+		poll = defaultTailPoll
+	}
+	t := &TailDecoder{Decoder: *NewDecoder(r)}
+	t.poll = poll
+	return t
+}
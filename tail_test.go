@@ -0,0 +1,72 @@
+package sjson
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// growingFile behaves like a file being appended to: reads return io.EOF
+// once they catch up with the data written so far.
+type growingFile struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (f *growingFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.buf.Read(p)
+}
+
+func (f *growingFile) WriteString(s string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buf.WriteString(s)
+}
+
+func TestTailDecoder(t *testing.T) {
+	f := &growingFile{}
+	f.WriteString("{\"a\": 1}\n{\"b\": ")
+	d := NewTailDecoder(f, time.Millisecond)
+
+	v, err := d.Next()
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, string(v))
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		f.WriteString("[2, ")
+		time.Sleep(20 * time.Millisecond)
+		f.WriteString("3]}\n42")
+	}()
+	v, err = d.Next()
+	require.NoError(t, err)
+	assert.Equal(t, `{"b":[2,3]}`, string(v))
+
+	// The number may continue, so it is held until the next byte.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = d.NextContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	f.WriteString("0\n")
+	v, err = d.Next()
+	require.NoError(t, err)
+	assert.Equal(t, `420`, string(v))
+}
+
+func TestTailDecoderErrors(t *testing.T) {
+	f := &growingFile{}
+	f.WriteString(`[1,]`)
+	d := NewTailDecoder(f, 0)
+	assert.Equal(t, defaultTailPoll, d.poll)
+	_, err := d.Next()
+	var syntaxErr *SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+}
@@ -0,0 +1,24 @@
+package sjson
+
+import "io"
+
+// SetTee makes the parser write every byte it is fed to w, verbatim and as
+// soon as it is received, including whitespace and bytes it goes on to
+// reject, so the exact input it saw can be captured for replay. Values
+// spliced by FeedRaw and bytes skipped by SkipToNextValue are written too.
+// Bytes are written one Feed at a time, so w should be buffered. An error
+// returned by w aborts parsing, and is returned by Feed. Passing nil stops
+// writing.
+func (p *Parser) SetTee(w io.Writer) {
+	p.tee = w
+}
+
+func (p *Parser) teeWrite(data []byte) error {
+	_, err := p.tee.Write(data)
+	return err
+}
+
+func (p *Parser) teeByte(b byte) error {
+	p.teeBuf[0] = b
+	return p.teeWrite(p.teeBuf[:])
+}
@@ -0,0 +1,55 @@
+package sjson
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTee(t *testing.T) {
+	var buf bytes.Buffer
+	p := &Parser{}
+	p.SetTee(&buf)
+	in := " {\"a\" :\t[1, \"x y\"]}\n 12 "
+	_, err := feedAll(p, in)
+	require.NoError(t, err)
+	assert.Equal(t, in, buf.String())
+
+	buf.Reset()
+	_, err = feedAll(p, "[1, x")
+	assert.Error(t, err)
+	_, err = feedAll(p, "yz")
+	assert.Error(t, err)
+	assert.Equal(t, "[1, x", buf.String(), "bytes fed after an error are not seen")
+
+	n := p.SkipToNextValue([]byte("yz] [2"))
+	assert.Equal(t, 4, n)
+	_, err = feedAll(p, "[2, ")
+	require.NoError(t, err)
+	require.NoError(t, p.FeedRaw([]byte(`{"raw":true}`), Object))
+	_, err = feedAll(p, "]")
+	require.NoError(t, err)
+	assert.Equal(t, `[1, xyz] [2, {"raw":true}]`, buf.String())
+
+	p.SetTee(nil)
+	_, err = feedAll(p, "3 ")
+	require.NoError(t, err)
+	assert.Equal(t, `[1, xyz] [2, {"raw":true}]`, buf.String())
+}
+
+func TestTeeError(t *testing.T) {
+	boom := errors.New("boom")
+	p := &Parser{}
+	p.SetTee(errWriter{boom})
+	_, err := feedAll(p, "[1]")
+	assert.ErrorIs(t, err, boom)
+	_, err = p.Feed('1')
+	assert.ErrorIs(t, err, boom)
+}
+
+type errWriter struct{ err error }
+
+func (w errWriter) Write([]byte) (int, error) { return 0, w.err }
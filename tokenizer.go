@@ -0,0 +1,135 @@
+package sjson
+
+// TokenKind identifies the kind of a Token.
+type TokenKind int
+
+const (
+	TokenBeginObject TokenKind = iota
+	TokenEndObject
+	TokenBeginArray
+	TokenEndArray
+	// TokenKey is an object key.
+	TokenKey
+	// TokenValue is a scalar value: a string, number, boolean or null.
+	TokenValue
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenBeginObject:
+		return "begin object"
+	case TokenEndObject:
+		return "end object"
+	case TokenBeginArray:
+		return "begin array"
+	case TokenEndArray:
+		return "end array"
+	case TokenKey:
+		return "key"
+	case TokenValue:
+		return "value"
+	default:
+		return "invalid"
+	}
+}
+
+// Token is a single syntactic element of a JSON document.
+type Token struct {
+	Kind TokenKind
+	// Type is the type of the value the token belongs to. Keys are
+	// reported as String.
+	Type ValueType
+	// Value holds the bytes of keys and scalar values as found in the
+	// input, including quotes for strings.
+	Value []byte
+	// Offset is the position of the token's first byte in the input.
+	Offset uint64
+}
+
+func scalarType(s parserState) ValueType {
+	switch s {
+	case pTrue, pFalse:
+		return Bool
+	case pNull:
+		return Null
+	case pString:
+		return String
+	default:
+		return Number
+	}
+}
+
+func (p *Parser) emitValueToken(s state) {
+	switch s.name {
+	case pArray:
+		p.tokenFn(Token{Kind: TokenEndArray, Type: Array, Offset: p.offset - 1})
+	case pObject:
+		p.tokenFn(Token{Kind: TokenEndObject, Type: Object, Offset: p.offset - 1})
+	default:
+		p.tokenFn(Token{Kind: TokenValue, Type: scalarType(s.name), Value: p.data[s.position:], Offset: s.offset})
+	}
+}
+
+// Tokenizer splits a stream of JSON values into tokens as bytes are fed to
+// it. The embedded Parser may be configured before feeding data.
+type Tokenizer struct {
+	Parser
+	tokens []Token
+}
+
+// NewTokenizer returns a new Tokenizer.
+func NewTokenizer() *Tokenizer {
+	t := &Tokenizer{}
+	t.tokenFn = func(tok Token) { t.tokens = append(t.tokens, tok) }
+	return t
+}
+
+// Feed feeds b to the tokenizer, returning the tokens it completed. Token
+// values alias the parser's buffer, and are only valid until the next call
+// to Feed.
+func (t *Tokenizer) Feed(b byte) ([]Token, error) {
+	t.tokens = t.tokens[:0]
+	_, err := t.Parser.Feed(b)
+	return t.tokens, err
+}
+
+// Finish signals the end of the input, returning the tokens completed by
+// it, such as a pending top-level number. It returns an error if the input
+// ends in the middle of a value.
+func (t *Tokenizer) Finish() ([]Token, error) {
+	t.tokens = t.tokens[:0]
+	if len(t.stack) == 0 {
+		return nil, nil
+	}
+	_, err := t.finish()
+	return t.tokens, err
+}
+
+// Tokens splits data, which may contain any number of values, into tokens.
+// If data is malformed, the tokens found before the error are returned along
+// with it.
+func Tokens(data []byte) ([]Token, error) {
+	t := NewTokenizer()
+	var tokens []Token
+	collect := func(toks []Token) {
+		for _, tok := range toks {
+			if tok.Value != nil {
+				tok.Value = append([]byte(nil), tok.Value...)
+			}
+			tokens = append(tokens, tok)
+		}
+	}
+	for _, b := range data {
+		toks, err := t.Feed(b)
+		collect(toks)
+		if err != nil {
+			return tokens, err
+		}
+	}
+	toks, err := t.Finish()
+	collect(toks)
+	if err == nil && len(tokens) == 0 {
+		err = t.syntaxError(emptyInputMsg, t.offset)
+	}
+	return tokens, err
+}
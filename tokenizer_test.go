@@ -0,0 +1,63 @@
+package sjson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokens(t *testing.T) {
+	tokens, err := Tokens([]byte(`{"a": [1, true], "b": null} "x" -12`))
+	require.NoError(t, err)
+	assert.Equal(t, []Token{
+		{Kind: TokenBeginObject, Type: Object, Offset: 0},
+		{Kind: TokenKey, Type: String, Value: []byte(`"a"`), Offset: 1},
+		{Kind: TokenBeginArray, Type: Array, Offset: 6},
+		{Kind: TokenValue, Type: Number, Value: []byte(`1`), Offset: 7},
+		{Kind: TokenValue, Type: Bool, Value: []byte(`true`), Offset: 10},
+		{Kind: TokenEndArray, Type: Array, Offset: 14},
+		{Kind: TokenKey, Type: String, Value: []byte(`"b"`), Offset: 17},
+		{Kind: TokenValue, Type: Null, Value: []byte(`null`), Offset: 22},
+		{Kind: TokenEndObject, Type: Object, Offset: 26},
+		{Kind: TokenValue, Type: String, Value: []byte(`"x"`), Offset: 28},
+		{Kind: TokenValue, Type: Number, Value: []byte(`-12`), Offset: 32},
+	}, tokens)
+}
+
+func TestTokensPartial(t *testing.T) {
+	tokens, err := Tokens([]byte(`[1, }`))
+	require.Error(t, err)
+	assert.Equal(t, []Token{
+		{Kind: TokenBeginArray, Type: Array, Offset: 0},
+		{Kind: TokenValue, Type: Number, Value: []byte(`1`), Offset: 1},
+	}, tokens)
+
+	tokens, err = Tokens([]byte(`{"a": `))
+	require.Error(t, err)
+	assert.Len(t, tokens, 2)
+
+	_, err = Tokens([]byte(`  `))
+	require.Error(t, err)
+}
+
+func TestTokensNonFinite(t *testing.T) {
+	tk := NewTokenizer()
+	tk.AllowNonFiniteNumbers = true
+	var tokens []Token
+	for _, b := range []byte(`[-Infinity]`) {
+		toks, err := tk.Feed(b)
+		require.NoError(t, err)
+		tokens = append(tokens, toks...)
+	}
+	require.Len(t, tokens, 3)
+	assert.Equal(t, TokenValue, tokens[1].Kind)
+	assert.Equal(t, Number, tokens[1].Type)
+	assert.Equal(t, uint64(1), tokens[1].Offset)
+}
+
+func TestTokenKindString(t *testing.T) {
+	assert.Equal(t, "begin object", TokenBeginObject.String())
+	assert.Equal(t, "value", TokenValue.String())
+	assert.Equal(t, "invalid", TokenKind(42).String())
+}
@@ -0,0 +1,68 @@
+package sjson
+
+// TraceKind identifies the kind of a TraceEvent.
+type TraceKind int
+
+const (
+	// TracePush is emitted when a state is pushed onto the stack.
+	TracePush TraceKind = iota
+	// TracePop is emitted when a state is popped from the stack.
+	TracePop
+	// TraceReplace is emitted when the state on top of the stack is
+	// replaced by another one.
+	TraceReplace
+	// TraceRetry is emitted when a state asks for the byte it was handed to
+	// be processed again, by the state then on top of the stack. This
+	// usually follows a pop, as values such as numbers only know they ended
+	// once they see the byte following them.
+	TraceRetry
+)
+
+func (k TraceKind) String() string {
+	switch k {
+	case TracePush:
+		return "push"
+	case TracePop:
+		return "pop"
+	case TraceReplace:
+		return "replace"
+	case TraceRetry:
+		return "retry"
+	default:
+		return "invalid"
+	}
+}
+
+// TraceEvent describes a step taken by the parser's state machine.
+type TraceEvent struct {
+	Kind TraceKind
+	// State names the state pushed, popped or replaced with, or, for
+	// retries, the state that asked for Byte to be reprocessed.
+	State string
+	// From names the state replaced, or the state on top of the stack when
+	// another one was pushed. It is empty for pushes onto an empty stack,
+	// pops and retries.
+	From string
+	// Byte is the byte being processed.
+	Byte byte
+	// Offset is the position of Byte in the input stream.
+	Offset uint64
+}
+
+// SetTracer registers fn to be called for every step taken by the parser's
+// state machine. It is meant for debugging, and state names are not part of
+// the package's stable API.
+func (p *Parser) SetTracer(fn func(e TraceEvent)) {
+	p.tracer = fn
+}
+
+func (p *Parser) trace(kind TraceKind, from, s parserState) {
+	e := TraceEvent{Kind: kind, State: s.String(), Byte: p.cur}
+	if from != pNone {
+		e.From = from.String()
+	}
+	if p.offset > 0 {
+		e.Offset = p.offset - 1
+	}
+	p.tracer(e)
+}
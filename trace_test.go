@@ -0,0 +1,45 @@
+package sjson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracer(t *testing.T) {
+	var events []TraceEvent
+	p := &Parser{}
+	p.SetTracer(func(e TraceEvent) { events = append(events, e) })
+	_, err := feedAll(p, `[1]`)
+	require.NoError(t, err)
+	assert.Equal(t, []TraceEvent{
+		{Kind: TracePush, State: "pArray", Byte: '[', Offset: 0},
+		{Kind: TracePush, State: "pNumber", From: "pArray", Byte: '1', Offset: 1},
+		{Kind: TracePop, State: "pNumber", Byte: ']', Offset: 2},
+		{Kind: TraceRetry, State: "pNumber", Byte: ']', Offset: 2},
+		{Kind: TracePop, State: "pArray", Byte: ']', Offset: 2},
+	}, events)
+
+	events = nil
+	_, err = feedAll(p, `{"a":1}`)
+	require.NoError(t, err)
+	var kinds []string
+	for _, e := range events {
+		kinds = append(kinds, e.Kind.String()+" "+e.From+">"+e.State)
+	}
+	assert.Equal(t, []string{
+		"push >pObject",
+		"push pObject>pObjectKey",
+		"retry >pObject",
+		"push pObjectKey>pString",
+		"pop >pString",
+		"replace pObjectKey>pObjectValue",
+		"push pObjectValue>pNumber",
+		"pop >pNumber",
+		"retry >pNumber",
+		"pop >pObjectValue",
+		"retry >pObjectValue",
+		"pop >pObject",
+	}, kinds)
+}
@@ -0,0 +1,41 @@
+package sjson
+
+// pNone stands for the empty stack, from which top-level values are pushed.
+const pNone parserState = -1
+
+// valueStates lists the states that may begin a value.
+var valueStates = []parserState{pFalse, pTrue, pNull, pString, pObject, pArray, pNumber, pNaN, pInfinity}
+
+// transitions describes the grammar implemented by the parser: for each state,
+// the states it may push, or be replaced with. It documents the state machine,
+// and is checked against the transitions taken while parsing by tests.
+var transitions = map[parserState][]parserState{
+	pNone:        valueStates,
+	pArray:       valueStates,
+	pObject:      {pObjectKey},
+	pObjectKey:   {pString, pObjectValue},
+	pObjectValue: append(append([]parserState(nil), valueStates...), pObjectKey),
+	pNumber:      {pNegInfinity},
+}
+
+// TransitionTable returns the grammar implemented by the parser: for each
+// state, named as in TraceEvent, the states it may push or be replaced with.
+// The empty name stands for the empty stack, from which top-level values are
+// pushed. The table is a copy, which callers may modify. Like TraceEvent,
+// state names are meant for documentation and debugging, and are not part
+// of the package's stable API.
+func TransitionTable() map[string][]string {
+	table := make(map[string][]string, len(transitions))
+	for from, to := range transitions {
+		name := ""
+		if from != pNone {
+			name = from.String()
+		}
+		names := make([]string, len(to))
+		for i, s := range to {
+			names[i] = s.String()
+		}
+		table[name] = names
+	}
+	return table
+}
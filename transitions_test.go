@@ -0,0 +1,70 @@
+package sjson
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitions(t *testing.T) {
+	type transition struct{ from, to string }
+	expected := map[transition]bool{}
+	for from, to := range TransitionTable() {
+		for _, s := range to {
+			expected[transition{from, s}] = true
+		}
+	}
+
+	observed := map[transition]bool{}
+	record := func(e TraceEvent) {
+		if e.Kind != TracePush && e.Kind != TraceReplace {
+			return
+		}
+		tr := transition{e.From, e.State}
+		observed[tr] = true
+		assert.True(t, expected[tr], "unexpected transition %q -> %q", tr.from, tr.to)
+	}
+
+	fixtures, err := os.ReadDir("fixtures")
+	require.NoError(t, err)
+	inputs := []string{
+		`[true, false, null, "s", -1, {}, [], NaN, Infinity, -Infinity]`,
+		`{"a": true, "b": false, "c": null, "d": "s", "e": 1, "f": {}, "g": [], "h": NaN, "i": Infinity}`,
+		`true`, `false`, `null`, `"s"`, `1 `, `{}`, `[]`, `NaN`, `Infinity`,
+	}
+	for _, f := range fixtures {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
+			data, err := os.ReadFile("fixtures/" + f.Name())
+			require.NoError(t, err)
+			inputs = append(inputs, string(data))
+		}
+	}
+	for _, in := range inputs {
+		p := &Parser{AllowNonFiniteNumbers: true}
+		p.SetTracer(record)
+		for _, b := range []byte(in) {
+			if _, err := p.Feed(b); err != nil {
+				break
+			}
+		}
+	}
+
+	for tr := range expected {
+		assert.True(t, observed[tr], "transition %q -> %q was never taken", tr.from, tr.to)
+	}
+}
+
+func TestTransitionTable(t *testing.T) {
+	table := TransitionTable()
+	assert.Len(t, table, len(transitions))
+	assert.Equal(t, []string{"pObjectKey"}, table["pObject"])
+	assert.Contains(t, table[""], "pArray")
+
+	table["pObject"][0] = "changed"
+	delete(table, "")
+	assert.Equal(t, []string{"pObjectKey"}, TransitionTable()["pObject"])
+	assert.Contains(t, TransitionTable(), "")
+}
@@ -0,0 +1,369 @@
+package sjson
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	minInternedInt = -128
+	maxInternedInt = 255
+)
+
+// internedInts holds pre-boxed values for integers in the range
+// [minInternedInt, maxInternedInt].
+var internedInts = func() []any {
+	v := make([]any, maxInternedInt-minInternedInt+1)
+	for i := range v {
+		v[i] = float64(i + minInternedInt)
+	}
+	return v
+}()
+
+// InvalidUTF8Policy determines how malformed UTF-8 found in strings is
+// handled when decoding them.
+type InvalidUTF8Policy int
+
+const (
+	// InvalidUTF8Error rejects strings containing malformed UTF-8.
+	InvalidUTF8Error InvalidUTF8Policy = iota
+	// InvalidUTF8Replace replaces each run of malformed UTF-8 bytes with the
+	// Unicode replacement character (U+FFFD), as strings.ToValidUTF8 does.
+	InvalidUTF8Replace
+)
+
+// NumberMode determines the Go type numbers are decoded into.
+type NumberMode int
+
+const (
+	// NumberFloat64 decodes every number into a float64.
+	NumberFloat64 NumberMode = iota
+	// NumberBigInt decodes integers, numbers with neither a fraction nor an
+	// exponent, into a *big.Int, so they keep their exact value whatever
+	// their size. Other numbers are decoded into a float64.
+	NumberBigInt
+)
+
+// maxExactInt is the largest magnitude below which every integer has an exact
+// float64 representation.
+const maxExactInt = 1 << 53
+
+// Unmarshaler decodes a JSON value into its Go representation: objects become
+// map[string]any, arrays []any, strings string, numbers float64, booleans
+// bool and null becomes nil.
+type Unmarshaler struct {
+	// InternSmallInts makes integers between -128 and 255 decode into shared,
+	// pre-boxed values instead of allocating a new interface value for each
+	// one. This trades a small, fixed amount of memory (one table shared by
+	// the whole process) and an extra check per number for fewer
+	// allocations on number-heavy inputs.
+	InternSmallInts bool
+
+	// InvalidUTF8 determines how malformed UTF-8 in strings and object keys
+	// is handled. By default, it is rejected.
+	InvalidUTF8 InvalidUTF8Policy
+
+	// NumberMode determines the Go type numbers are decoded into. By
+	// default, they are decoded into float64.
+	NumberMode NumberMode
+
+	// RejectInexactIntegers makes NumberFloat64 reject integers whose
+	// magnitude is greater than 2^53 (9007199254740992), as not all of them
+	// can be represented exactly by a float64. Use NumberBigInt to decode
+	// them precisely instead.
+	RejectInexactIntegers bool
+
+	// AllowHexEscapes decodes the non-standard \xHH escape in strings into
+	// the code point U+00HH, as JavaScript does, instead of rejecting it.
+	AllowHexEscapes bool
+
+	// escapes holds the escapes registered with RegisterEscape.
+	escapes map[byte]customEscape
+}
+
+// RegisterEscape makes the unmarshaler decode the non-standard escape
+// sequence made of a backslash, char, and the given number of hexadecimal
+// digits with decode, instead of rejecting it. See Parser.RegisterEscape.
+func (u *Unmarshaler) RegisterEscape(char byte, digits int, decode EscapeDecoder) {
+	if isStandardEscape(char) || decode == nil {
+		return
+	}
+	if u.escapes == nil {
+		u.escapes = map[byte]customEscape{}
+	}
+	u.escapes[char] = customEscape{digits: digits, decode: decode}
+}
+
+// Unmarshal decodes the single JSON value contained in data using default
+// options.
+func Unmarshal(data []byte) (any, error) {
+	return (&Unmarshaler{}).Unmarshal(data)
+}
+
+// Unmarshal decodes the single JSON value contained in data.
+func (u *Unmarshaler) Unmarshal(data []byte) (any, error) {
+	value, err := parseSingle(&Parser{}, data)
+	if err != nil {
+		return nil, err
+	}
+	d := valueDecoder{data: value, u: u}
+	return d.decode()
+}
+
+// valueDecoder decodes a value previously validated by a Parser.
+type valueDecoder struct {
+	data []byte
+	pos  int
+	u    *Unmarshaler
+}
+
+func (d *valueDecoder) skipWsp() {
+	for d.pos < len(d.data) && isWsp(d.data[d.pos]) {
+		d.pos++
+	}
+}
+
+func (d *valueDecoder) decode() (any, error) {
+	d.skipWsp()
+	switch d.data[d.pos] {
+	case 't':
+		d.pos += len("true")
+		return true, nil
+	case 'f':
+		d.pos += len("false")
+		return false, nil
+	case 'n':
+		d.pos += len("null")
+		return nil, nil
+	case quote:
+		return d.decodeString()
+	case leftSquared:
+		return d.decodeArray()
+	case leftCurly:
+		return d.decodeObject()
+	default:
+		return d.decodeNumber()
+	}
+}
+
+func (d *valueDecoder) decodeArray() (any, error) {
+	arr := []any{}
+	d.pos++
+	for {
+		d.skipWsp()
+		switch d.data[d.pos] {
+		case rightSquared:
+			d.pos++
+			return arr, nil
+		case ',':
+			d.pos++
+			continue
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+}
+
+func (d *valueDecoder) decodeObject() (any, error) {
+	obj := map[string]any{}
+	d.pos++
+	for {
+		d.skipWsp()
+		switch d.data[d.pos] {
+		case rightCurly:
+			d.pos++
+			return obj, nil
+		case ',':
+			d.pos++
+			continue
+		}
+		key, err := d.decodeString()
+		if err != nil {
+			return nil, err
+		}
+		d.skipWsp()
+		d.pos++ // ':'
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		obj[key] = v
+	}
+}
+
+func (d *valueDecoder) decodeString() (string, error) {
+	start := d.pos
+	d.pos++
+	for d.data[d.pos] != quote {
+		if d.data[d.pos] == '\\' {
+			d.pos++
+		}
+		d.pos++
+	}
+	d.pos++
+	s, err := unescapeString(d.data[start:d.pos], escapeSet{hex: d.u.AllowHexEscapes, custom: d.u.escapes})
+	if err != nil || utf8.ValidString(s) {
+		return s, err
+	}
+	if d.u.InvalidUTF8 == InvalidUTF8Replace {
+		return strings.ToValidUTF8(s, string(utf8.RuneError)), nil
+	}
+	return "", fmt.Errorf("invalid string: malformed UTF-8")
+}
+
+func (d *valueDecoder) decodeNumber() (any, error) {
+	start := d.pos
+	integer := true
+	for d.pos < len(d.data) {
+		c := d.data[d.pos]
+		if c == '.' || c == 'e' || c == 'E' {
+			integer = false
+		} else if c != '-' && c != '+' && c != '_' && (c < '0' || c > '9') {
+			break
+		}
+		d.pos++
+	}
+	raw := stripDigitSeparators(d.data[start:d.pos])
+	if integer && d.u.NumberMode == NumberBigInt {
+		n, ok := new(big.Int).SetString(string(raw), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid number %s", raw)
+		}
+		return n, nil
+	}
+	if integer && d.u.RejectInexactIntegers {
+		n, err := strconv.ParseInt(string(raw), 10, 64)
+		if err != nil || n > maxExactInt || n < -maxExactInt {
+			return nil, fmt.Errorf("number %s can't be represented exactly as a float64", raw)
+		}
+	}
+	if d.u.InternSmallInts && integer && len(raw) <= 4 {
+		if n, err := strconv.Atoi(string(raw)); err == nil && n >= minInternedInt && n <= maxInternedInt && (n != 0 || raw[0] != '-') {
+			return internedInts[n-minInternedInt], nil
+		}
+	}
+	f, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %s: %w", raw, err)
+	}
+	return f, nil
+}
+
+// ParseNumber converts a number returned by the parser into a float64,
+// removing the digit separators accepted by AllowDigitSeparators first.
+func ParseNumber(raw []byte) (float64, error) {
+	f, err := strconv.ParseFloat(string(stripDigitSeparators(raw)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %s: %w", raw, err)
+	}
+	return f, nil
+}
+
+func stripDigitSeparators(raw []byte) []byte {
+	if bytes.IndexByte(raw, '_') < 0 {
+		return raw
+	}
+	return bytes.ReplaceAll(raw, []byte("_"), nil)
+}
+
+// UnescapeString takes a quoted JSON string, as returned by the parser, and
+// returns its contents with all escape sequences resolved, including UTF-16
+// surrogate pairs. Invalid surrogates are replaced by U+FFFD. Only escapes
+// defined by JSON are accepted.
+func UnescapeString(raw []byte) (string, error) {
+	return unescapeString(raw, escapeSet{})
+}
+
+func unescapeString(raw []byte, escapes escapeSet) (string, error) {
+	if len(raw) < 2 || raw[0] != quote || raw[len(raw)-1] != quote {
+		return "", fmt.Errorf("invalid string: missing quotes")
+	}
+	raw = raw[1 : len(raw)-1]
+	out := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c != '\\' {
+			out = append(out, c)
+			continue
+		}
+		i++
+		if i >= len(raw) {
+			return "", fmt.Errorf("invalid string: incomplete escape sequence")
+		}
+		switch raw[i] {
+		case '"', '\\', '/':
+			out = append(out, raw[i])
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'u':
+			r, ok := readHex4(raw[i+1:])
+			if !ok {
+				return "", fmt.Errorf("invalid string: invalid \\u escape")
+			}
+			i += 4
+			if utf16.IsSurrogate(r) {
+				r2, ok := rune(0), false
+				if i+2 < len(raw) && raw[i+1] == '\\' && raw[i+2] == 'u' {
+					r2, ok = readHex4(raw[i+3:])
+				}
+				if dec := utf16.DecodeRune(r, r2); ok && dec != utf8.RuneError {
+					r = dec
+					i += 6
+				} else {
+					r = utf8.RuneError
+				}
+			}
+			out = utf8.AppendRune(out, r)
+		default:
+			var err error
+			if out, i, err = escapes.resolve(out, raw, i); err != nil {
+				return "", err
+			}
+		}
+	}
+	return string(out), nil
+}
+
+func byteAt(b []byte, i int) byte {
+	if i >= len(b) {
+		return 0
+	}
+	return b[i]
+}
+
+func readHex4(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range b[:4] {
+		r <<= 4
+		switch {
+		case c >= '0' && c <= '9':
+			r |= rune(c - '0')
+		case c >= 'a' && c <= 'f':
+			r |= rune(c - 'a' + 10)
+		case c >= 'A' && c <= 'F':
+			r |= rune(c - 'A' + 10)
+		default:
+			return 0, false
+		}
+	}
+	return r, true
+}
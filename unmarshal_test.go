@@ -0,0 +1,141 @@
+package sjson
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshal(t *testing.T) {
+	v, err := Unmarshal([]byte(` {"a": [1, -2.5e1, "x\nyé😀"], "b": {"c": null, "d": true}, "e": false} `))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"a": []any{float64(1), float64(-25), "x\nyé😀"},
+		"b": map[string]any{"c": nil, "d": true},
+		"e": false,
+	}, v)
+}
+
+func TestUnmarshalTopLevelNumber(t *testing.T) {
+	v, err := Unmarshal([]byte("42"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(42), v)
+
+	v, err = Unmarshal([]byte(" 42 \n"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(42), v)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, v := range []string{"", "   ", "[1,", "1 2", "{} x", "-"} {
+		t.Run("rejects "+v, func(t *testing.T) {
+			_, err := Unmarshal([]byte(v))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestUnmarshalInternSmallInts(t *testing.T) {
+	u := &Unmarshaler{InternSmallInts: true}
+	v, err := u.Unmarshal([]byte("[-128, 0, 7, 255, 256, -129, 1.5]"))
+	require.NoError(t, err)
+	assert.Equal(t, []any{float64(-128), float64(0), float64(7), float64(255), float64(256), float64(-129), 1.5}, v)
+
+	// -0 keeps its sign, rather than decoding into the interned 0.
+	v, err = u.Unmarshal([]byte("[-0, 0]"))
+	require.NoError(t, err)
+	assert.True(t, math.Signbit(v.([]any)[0].(float64)))
+	assert.False(t, math.Signbit(v.([]any)[1].(float64)))
+
+	allocs := func(u *Unmarshaler) float64 {
+		return testing.AllocsPerRun(10, func() {
+			_, _ = u.Unmarshal([]byte("[1,2,3,4,5,6,7,8]"))
+		})
+	}
+	assert.Less(t, allocs(&Unmarshaler{InternSmallInts: true}), allocs(&Unmarshaler{}))
+}
+
+func benchmarkUnmarshalInts(b *testing.B, intern bool) {
+	data := []byte("[" + strings.Repeat("1,22,133,-4,", 256) + "0]")
+	u := &Unmarshaler{InternSmallInts: intern}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if _, err := u.Unmarshal(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalSmallInts(b *testing.B)         { benchmarkUnmarshalInts(b, false) }
+func BenchmarkUnmarshalInternedSmallInts(b *testing.B) { benchmarkUnmarshalInts(b, true) }
+
+func TestUnmarshalInvalidUTF8(t *testing.T) {
+	data := []byte("{\"k\xff\": [\"a\xc3\x28b\", \"ok\"]}")
+	_, err := Unmarshal(data)
+	assert.ErrorContains(t, err, "malformed UTF-8")
+
+	u := &Unmarshaler{InvalidUTF8: InvalidUTF8Replace}
+	v, err := u.Unmarshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"k�": []any{"a�(b", "ok"}}, v)
+
+	v, err = Unmarshal([]byte(`"café ☕"`))
+	require.NoError(t, err)
+	assert.Equal(t, "café ☕", v)
+}
+
+func TestUnescapeString(t *testing.T) {
+	cases := map[string]string{
+		`""`:                      "",
+		`"plain"`:                 "plain",
+		`"a\nb\tc\rd\be\ff"`:      "a\nb\tc\rd\be\ff",
+		`"\"\\\/"`:                `"\/`,
+		`"\u00e9A"`:               "\u00e9A",
+		`"nul\u0000byte"`:         "nul\x00byte",
+		`"\ud83d\ude00!"`:         "\U0001F600!",
+		`"\ud83d"`:                "\uFFFD",
+		`"\ude00x"`:               "\uFFFDx",
+		`"\ud83d\u0041"`:          "\uFFFDA",
+		`"caf` + "\xc3\xa9" + `"`: "café",
+	}
+	for in, expected := range cases {
+		s, err := UnescapeString([]byte(in))
+		require.NoError(t, err, in)
+		assert.Equal(t, expected, s, in)
+	}
+
+	for _, in := range []string{``, `"`, `abc`, `"\x"`, `"\u12"`, `"\u12g4"`, `"\"`} {
+		_, err := UnescapeString([]byte(in))
+		assert.Error(t, err, in)
+	}
+}
+
+func TestUnmarshalRejectInexactIntegers(t *testing.T) {
+	u := &Unmarshaler{RejectInexactIntegers: true}
+	for _, in := range []string{"9007199254740992", "-9007199254740992", "[1, 2.5, 1e300]", "9007199254740993.0"} {
+		_, err := u.Unmarshal([]byte(in))
+		assert.NoError(t, err, in)
+	}
+	for _, in := range []string{"9007199254740993", "-9007199254740993", "[99999999999999999999]"} {
+		_, err := u.Unmarshal([]byte(in))
+		assert.Error(t, err, in)
+	}
+
+	v, err := Unmarshal([]byte("9007199254740993"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(9007199254740992), v)
+}
+
+func TestUnmarshalNumberBigInt(t *testing.T) {
+	u := &Unmarshaler{NumberMode: NumberBigInt, RejectInexactIntegers: true}
+	v, err := u.Unmarshal([]byte(`[9007199254740993, -99999999999999999999, 1.5, 2e3]`))
+	require.NoError(t, err)
+	big1, _ := new(big.Int).SetString("9007199254740993", 10)
+	big2, _ := new(big.Int).SetString("-99999999999999999999", 10)
+	assert.Equal(t, []any{big1, big2, 1.5, float64(2000)}, v)
+}
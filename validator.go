@@ -0,0 +1,82 @@
+package sjson
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Validator checks that a stream of JSON values is well formed without
+// keeping their bytes, so its memory use only grows with the nesting depth
+// of the values, regardless of their size. As a consequence, it can't return
+// the values it validates; use a Parser for that. The zero value is ready to
+// use.
+type Validator struct {
+	p Parser
+}
+
+// Feed feeds b to the validator, returning whether it completed a top-level
+// value. Once Feed returns an error, every further call returns the same
+// error until Reset is called.
+func (v *Validator) Feed(b byte) (complete bool, err error) {
+	v.p.discard = true
+	data, err := v.p.Feed(b)
+	return data != nil, err
+}
+
+// Finish signals the end of the input, returning whether it completed a
+// pending top-level number. It returns an error if the input ends in the
+// middle of a value.
+func (v *Validator) Finish() (complete bool, err error) {
+	if len(v.p.stack) == 0 {
+		return false, nil
+	}
+	if _, err := v.p.finish(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Offset returns how many bytes were fed to the validator.
+func (v *Validator) Offset() uint64 {
+	return v.p.Offset()
+}
+
+// Reset discards all validation state, so the validator can be reused for a
+// new stream.
+func (v *Validator) Reset() {
+	v.p.Reset()
+}
+
+// ValidateBatch checks that each of docs holds exactly one well-formed JSON
+// value, as Parse would, spreading the work across workers goroutines, or
+// GOMAXPROCS when workers isn't positive. The returned slice holds an error,
+// or nil, for each document, in the same order as docs.
+func ValidateBatch(docs [][]byte, workers int) []error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(docs) {
+		workers = len(docs)
+	}
+	errs := make([]error, len(docs))
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			p := &Parser{discard: true}
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(docs) {
+					return
+				}
+				p.Reset()
+				_, errs[i] = parseSingle(p, docs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
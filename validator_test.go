@@ -0,0 +1,150 @@
+package sjson
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validate feeds data to v, returning how many values it completed.
+func validate(v *Validator, data []byte) (int, error) {
+	n := 0
+	for _, b := range data {
+		complete, err := v.Feed(b)
+		if err != nil {
+			return n, err
+		}
+		if complete {
+			n++
+		}
+	}
+	complete, err := v.Finish()
+	if complete {
+		n++
+	}
+	return n, err
+}
+
+func TestValidator(t *testing.T) {
+	v := &Validator{}
+	data := []byte(`{"a": [1, -2.5e3, true, "x\"y"]} "s" 12`)
+	n, err := validate(v, data)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, uint64(len(data)), v.Offset())
+
+	for _, in := range []string{`[1,]`, `{"a" 1}`, `[-10.5.1]`, `[fasle]`, `["abc`, `01`} {
+		v.Reset()
+		_, err := validate(v, []byte(in))
+		assert.Error(t, err, in)
+	}
+}
+
+func TestValidatorConstantMemory(t *testing.T) {
+	v := &Validator{}
+	data := "[" + strings.Repeat(`{"key": "`+strings.Repeat("x", 100)+`", "n": -1234.5678e10},`, 1000) + `[[["deep"]]]]`
+	n, err := validate(v, []byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Less(t, cap(v.p.data), 64)
+}
+
+func TestValidatorConstantMemoryLargeObject(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i < 100000; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(`"k` + strconv.Itoa(i) + `": [1, {"x": true}]`)
+	}
+	sb.WriteByte('}')
+
+	v := &Validator{}
+	n, err := validate(v, []byte(sb.String()))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Less(t, cap(v.p.data), 64)
+}
+
+func TestValidatorMatchesParser(t *testing.T) {
+	fixtures, err := os.ReadDir("fixtures")
+	require.NoError(t, err)
+	for _, f := range fixtures {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile("fixtures/" + f.Name())
+		require.NoError(t, err)
+
+		p := &Parser{}
+		want := 0
+		var wantErr error
+		for _, b := range data {
+			var v []byte
+			if v, wantErr = p.Feed(b); wantErr != nil {
+				break
+			}
+			if v != nil {
+				want++
+			}
+		}
+		if wantErr == nil && len(p.stack) > 0 {
+			if _, wantErr = p.finish(); wantErr == nil {
+				want++
+			}
+		}
+
+		got, err := validate(&Validator{}, data)
+		assert.Equal(t, wantErr, err, f.Name())
+		if wantErr == nil {
+			assert.Equal(t, want, got, f.Name())
+		}
+	}
+}
+
+func TestValidateBatch(t *testing.T) {
+	var docs [][]byte
+	for i := 0; i < 200; i++ {
+		switch i % 4 {
+		case 0:
+			docs = append(docs, []byte(`{"i": [1, 2, {"x": "y"}]}`))
+		case 1:
+			docs = append(docs, []byte(`[1, 2,]`))
+		case 2:
+			docs = append(docs, []byte(` 12 `))
+		case 3:
+			docs = append(docs, []byte(`[1] [2]`))
+		}
+	}
+	for _, workers := range []int{0, 1, 3, 500} {
+		errs := ValidateBatch(docs, workers)
+		require.Len(t, errs, len(docs))
+		for i, err := range errs {
+			_, want := Parse(docs[i])
+			assert.Equal(t, want, err, "workers=%d doc=%d", workers, i)
+		}
+	}
+	assert.Empty(t, ValidateBatch(nil, 4))
+}
+
+func BenchmarkValidateBatch(b *testing.B) {
+	doc, err := os.ReadFile("fixtures/limits/nodes_155.json")
+	require.NoError(b, err)
+	docs := make([][]byte, 1000)
+	for i := range docs {
+		docs[i] = doc
+	}
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(strconv.Itoa(workers), func(b *testing.B) {
+			b.SetBytes(int64(len(doc) * len(docs)))
+			for i := 0; i < b.N; i++ {
+				ValidateBatch(docs, workers)
+			}
+		})
+	}
+}